@@ -2,30 +2,70 @@ package main
 
 import "fmt"
 import "os"
-import "bufio"
-import "strings"
+import "io"
 import "goober-lisp/goober"
 import "runtime/debug"
-import "io/ioutil"
 
-func isEmpty(s string) bool {
-	t := strings.TrimSpace(s)
-	return len(t) == 0
-}
-
-func handle(ns *goober.Ns, input string) {
+func handle(ns *goober.Ns, val goober.Value) {
 
 	// not supposed to panic across packages, but too bad
 	defer func() {
-		if e := recover(); e != nil {
+		e := recover()
+		if ex, ok := e.(goober.ExInfo); ok {
+			fmt.Printf("error: %s\n%s", ex, ex.Backtrace())
+		} else if e != nil {
 			fmt.Printf("%s: %s", e, debug.Stack())
 		}
 	}()
 
-	if !isEmpty(input) {
-		for _, val := range goober.Read(input) {
-			fmt.Printf("%v\n", goober.Eval(ns, val))
+	// printed before anything is written, so that an error realizing a lazy
+	// result is reported as an error rather than printed as the result
+	fmt.Println(goober.Prn(goober.Eval(ns, val)))
+}
+
+// Evaluates every form in a script as it is read, exiting with a message if
+// the script cannot be read, or with a backtrace if it throws.
+func run(ns *goober.Ns, r *goober.Reader) {
+
+	defer func() {
+		e := recover()
+		if ex, ok := e.(goober.ExInfo); ok {
+			fmt.Fprintf(os.Stderr, "error: %s\n%s", ex, ex.Backtrace())
+			os.Exit(1)
+		} else if e != nil {
+			panic(e)
+		}
+	}()
+
+	for {
+		val, err := r.ReadForm()
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "read error: %v\n", err)
+			os.Exit(1)
+		}
+
+		goober.Eval(ns, val)
+	}
+}
+
+func repl(ns *goober.Ns, r *goober.Reader) {
+	for {
+		fmt.Print(ns.Name + "> ")
+
+		val, err := r.ReadForm()
+		if err == io.EOF {
+			break
+		} else if _, ok := err.(*goober.ReadError); ok {
+			fmt.Printf("read error: %v\n", err)
+			r.SkipLine()
+			continue
+		} else if err != nil {
+			break
 		}
+
+		handle(ns, val)
 	}
 }
 
@@ -36,46 +76,19 @@ func main() {
 
 	if len(os.Args) > 1 { // read from file
 
-		data, err := ioutil.ReadFile(os.Args[1])
+		f, err := os.Open(os.Args[1])
 		if err != nil {
-			panic(fmt.Sprintf("error reading input", err))
+			panic(fmt.Sprintf("error reading input: %v", err))
 		}
+		defer f.Close()
 
-		lines := strings.Split(string(data), "\n")
-		filtered := make([]string, 0)
-		for _, l := range lines {
-			if !strings.HasPrefix(l, "#!") {
-				filtered = append(filtered, l)
-			}
-		}
-		done := strings.Join(filtered, "\n")
-
-		for _, val := range goober.Read(done) {
-			goober.Eval(ns, val)
-		}
+		run(ns, goober.NewReader(os.Args[1], f))
 
 	} else if (stat.Mode() & os.ModeCharDevice) == 0 { // handle piped lisp script
 
-		reader := bufio.NewReader(os.Stdin)
-		data, err := ioutil.ReadAll(reader)
-		if err != nil {
-			panic(fmt.Sprintf("error reading input", err))
-		}
+		run(ns, goober.NewReader("stdin", os.Stdin))
 
-		for _, val := range goober.Read(string(data)) {
-			goober.Eval(ns, val)
-		}
 	} else { // fall back to repl
-		for {
-			reader := bufio.NewReader(os.Stdin)
-			fmt.Print(ns.Name + "> ")
-
-			input, err := reader.ReadString('\n')
-			if err != nil {
-				break
-			}
-
-			handle(ns, input)
-		}
+		repl(ns, goober.NewReader("repl", os.Stdin))
 	}
 }
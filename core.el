@@ -1,41 +1,20 @@
+;; The core library, evaluated into the default namespace at startup.
+
 (defmacro defn (name args & rest)
-  (let (f (cons 'fn (cons args rest)))
-   (list 'def name f)))
+  `(def ~name (fn ~args ~@rest)))
 
 (defn not (x) (if x false true))
 
-(defmacro when (test & rest) 
-  (list 'if test (cons 'do rest)))
+(defmacro when (test & rest)
+  `(if ~test (do ~@rest)))
 
-(defn empty? (coll) (= (count coll) 0))
+(defn empty? (coll) (not (seq coll))) ; without counting, which never ends for an infinite seq
 
 (defn second (coll) (first (rest coll)))
 
 (defn inc (n) (+ n 1))
 (defn dec (n) (- n 1))
 
-(defn reverse (coll)
-  (let (map-inner (fn (old-coll new-coll)
-                    (if (empty? old-coll)
-                      new-coll
-                      (recur
-                        (rest old-coll)
-                        (cons (first old-coll) new-coll)))))
-    (map-inner coll (list))))
-
-(defn map (f coll)
-  (let (map-inner (fn (old-coll new-coll)
-                    (if (empty? old-coll)
-                      new-coll
-                      (recur
-                        (rest old-coll)
-                        (cons (f (first old-coll)) new-coll))))
-        mapped (map-inner (seq coll) (list)))
-    (reverse mapped)))
-
-(defmacro apply (f & rest)
-  (cons f rest))
-
 (defn and (& args)
   (if (empty? args)
     true
@@ -44,11 +23,10 @@
         false)))
 
 (defn fib (n)
-  (let (fib-n (fn (x n)
-                (if (< (count x) n) 
-                  (recur (cons (+ (first x) (second x)) x) n)
-                  (reverse x))))
-    (fib-n '(1 0) n)))
+  (loop (x '(1 0))
+    (if (< (count x) n)
+      (recur (cons (+ (first x) (second x)) x))
+      (reverse x))))
 
 (defn describe-person (name & other-stuff)
   (println "name:" name)
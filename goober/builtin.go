@@ -2,6 +2,7 @@ package goober
 
 import "math"
 import "fmt"
+import "sort"
 import "strings"
 
 type builtin_f func([]Value) Value
@@ -39,32 +40,108 @@ func makeBuiltin(name string, f func([]Value) Value) IFn {
 	return builtin{name: name, f: builtin_f(f)}
 }
 
+var builtinMap map[string]IFn
+
+// populated here rather than in the declaration, since builtins that call
+// functions refer back to eval, which refers to this map
 // TODO: would be nice to avoid this duplication
-var builtinMap = map[string]IFn{
-	"list":     makeBuiltin("list", builtin_list),
-	"first":    makeBuiltin("first", builtin_first),
-	"last":     makeBuiltin("last", builtin_last),
-	"rest":     makeBuiltin("rest", builtin_rest),
-	"nth":      makeBuiltin("nth", builtin_nth),
-	"cons":     makeBuiltin("cons", builtin_cons),
-	"+":        makeBuiltin("+", builtin_plus),
-	"-":        makeBuiltin("-", builtin_minus),
-	"=":        makeBuiltin("=", builtin_eq),
-	">":        makeBuiltin(">", builtin_gt),
-	">=":       makeBuiltin(">=", builtin_gteq),
-	"<":        makeBuiltin("<", builtin_lt),
-	"<=":       makeBuiltin("<=", builtin_lteq),
-	"hash-map": makeBuiltin("hash-map", builtin_hashmap),
-	"get":      makeBuiltin("get", builtin_get),
-	"put":      makeBuiltin("put", builtin_put),
-	"seq":      makeBuiltin("seq", builtin_seq),
-	"println":  makeBuiltin("println", builtin_println),
-	"count":    makeBuiltin("count", builtin_count),
-	"str":      makeBuiltin("str", builtin_str),
+func init() {
+	builtinMap = map[string]IFn{
+		"list":          makeBuiltin("list", builtin_list),
+		"first":         makeBuiltin("first", builtin_first),
+		"last":          makeBuiltin("last", builtin_last),
+		"rest":          makeBuiltin("rest", builtin_rest),
+		"nth":           makeBuiltin("nth", builtin_nth),
+		"cons":          makeBuiltin("cons", builtin_cons),
+		"+":             makeBuiltin("+", builtin_plus),
+		"-":             makeBuiltin("-", builtin_minus),
+		"*":             makeBuiltin("*", builtin_times),
+		"/":             makeBuiltin("/", builtin_divide),
+		"=":             makeBuiltin("=", builtin_eq),
+		"==":            makeBuiltin("==", builtin_numeq),
+		"not=":          makeBuiltin("not=", builtin_not_eq),
+		"hash":          makeBuiltin("hash", builtin_hash),
+		">":             makeBuiltin(">", builtin_gt),
+		">=":            makeBuiltin(">=", builtin_gteq),
+		"<":             makeBuiltin("<", builtin_lt),
+		"<=":            makeBuiltin("<=", builtin_lteq),
+		"hash-map":      makeBuiltin("hash-map", builtin_hashmap),
+		"get":           makeBuiltin("get", builtin_get),
+		"put":           makeBuiltin("put", builtin_put),
+		"assoc":         makeBuiltin("assoc", builtin_assoc),
+		"dissoc":        makeBuiltin("dissoc", builtin_dissoc),
+		"update":        makeBuiltin("update", builtin_update),
+		"merge":         makeBuiltin("merge", builtin_merge),
+		"keys":          makeBuiltin("keys", builtin_keys),
+		"vals":          makeBuiltin("vals", builtin_vals),
+		"contains?":     makeBuiltin("contains?", builtin_contains),
+		"hash-set":      makeBuiltin("hash-set", builtin_hashset),
+		"disj":          makeBuiltin("disj", builtin_disj),
+		"union":         makeBuiltin("union", builtin_union),
+		"intersection":  makeBuiltin("intersection", builtin_intersection),
+		"difference":    makeBuiltin("difference", builtin_difference),
+		"subset?":       makeBuiltin("subset?", builtin_subset),
+		"select":        makeBuiltin("select", builtin_select),
+		"vector":        makeBuiltin("vector", builtin_vector),
+		"conj":          makeBuiltin("conj", builtin_conj),
+		"subvec":        makeBuiltin("subvec", builtin_subvec),
+		"peek":          makeBuiltin("peek", builtin_peek),
+		"pop":           makeBuiltin("pop", builtin_pop),
+		"sorted-map":    makeBuiltin("sorted-map", builtin_sorted_map),
+		"sorted-map-by": makeBuiltin("sorted-map-by", builtin_sorted_map_by),
+		"sorted-set":    makeBuiltin("sorted-set", builtin_sorted_set),
+		"sorted-set-by": makeBuiltin("sorted-set-by", builtin_sorted_set_by),
+		"compare":       makeBuiltin("compare", builtin_compare),
+		"subseq":        makeBuiltin("subseq", builtin_subseq),
+		"rsubseq":       makeBuiltin("rsubseq", builtin_rsubseq),
+		"range":         makeBuiltin("range", builtin_range),
+		"iterate":       makeBuiltin("iterate", builtin_iterate),
+		"repeat":        makeBuiltin("repeat", builtin_repeat),
+		"cycle":         makeBuiltin("cycle", builtin_cycle),
+		"take":          makeBuiltin("take", builtin_take),
+		"drop":          makeBuiltin("drop", builtin_drop),
+		"take-while":    makeBuiltin("take-while", builtin_take_while),
+		"concat":        makeBuiltin("concat", builtin_concat),
+		"map":           makeBuiltin("map", builtin_map),
+		"filter":        makeBuiltin("filter", builtin_filter),
+		"reduce":        makeBuiltin("reduce", builtin_reduce),
+		"reverse":       makeBuiltin("reverse", builtin_reverse),
+		"sort":          makeBuiltin("sort", builtin_sort),
+		"group-by":      makeBuiltin("group-by", builtin_group_by),
+		"partition":     makeBuiltin("partition", builtin_partition),
+		"partition-all": makeBuiltin("partition-all", builtin_partition_all),
+		"frequencies":   makeBuiltin("frequencies", builtin_frequencies),
+		"distinct":      makeBuiltin("distinct", builtin_distinct),
+		"interleave":    makeBuiltin("interleave", builtin_interleave),
+		"some":          makeBuiltin("some", builtin_some),
+		"every?":        makeBuiltin("every?", builtin_every),
+		"apply":         makeBuiltin("apply", builtin_apply),
+		"partial":       makeBuiltin("partial", builtin_partial),
+		"comp":          makeBuiltin("comp", builtin_comp),
+		"juxt":          makeBuiltin("juxt", builtin_juxt),
+		"complement":    makeBuiltin("complement", builtin_complement),
+		"identity":      makeBuiltin("identity", builtin_identity),
+		"constantly":    makeBuiltin("constantly", builtin_constantly),
+		"transduce":     makeBuiltin("transduce", builtin_transduce),
+		"into":          makeBuiltin("into", builtin_into),
+		"sequence":      makeBuiltin("sequence", builtin_sequence),
+		"eduction":      makeBuiltin("eduction", builtin_eduction),
+		"reduced":       makeBuiltin("reduced", builtin_reduced),
+		"reduced?":      makeBuiltin("reduced?", builtin_is_reduced),
+		"seq":           makeBuiltin("seq", builtin_seq),
+		"println":       makeBuiltin("println", builtin_println),
+		"count":         makeBuiltin("count", builtin_count),
+		"str":           makeBuiltin("str", builtin_str),
+		"gensym":        makeBuiltin("gensym", builtin_gensym),
+		"ex-info":       makeBuiltin("ex-info", builtin_ex_info),
+		"ex-message":    makeBuiltin("ex-message", builtin_ex_message),
+		"ex-data":       makeBuiltin("ex-data", builtin_ex_data),
+		"ex-cause":      makeBuiltin("ex-cause", builtin_ex_cause),
+	}
 }
 
 func builtin_list(vals []Value) Value {
-	return Sexpr(vals)
+	return NewList(vals...)
 }
 
 func builtin_first(vals []Value) Value {
@@ -73,26 +150,30 @@ func builtin_first(vals []Value) Value {
 		panic(fmt.Sprintf("first takes only 1 parameter: %v", vals))
 	}
 
-	seq := seq(vals[0])
-	if len(seq) == 0 {
+	seq := toSeq(vals[0])
+	if seq == nil {
 		return Nil{}
 	} else {
-		return seq[0]
+		return seq.First()
 	}
 }
 
 func builtin_last(vals []Value) Value {
 
 	if len(vals) != 1 {
-		panic(fmt.Sprintf("first takes only 1 parameter: %v", vals))
+		panic(fmt.Sprintf("last takes only 1 parameter: %v", vals))
 	}
 
-	seq := seq(vals[0])
-	if len(seq) == 0 {
-		return Nil{}
-	} else {
-		return seq[len(seq)-1]
+	if v, ok := vals[0].(Vector); ok && v.Count() > 0 {
+		last, _ := v.Get(v.Count() - 1)
+		return last
+	}
+
+	var last Value = Nil{}
+	for seq := toSeq(vals[0]); seq != nil; seq = seq.Next() {
+		last = seq.First()
 	}
+	return last
 }
 
 func builtin_rest(vals []Value) Value {
@@ -101,25 +182,48 @@ func builtin_rest(vals []Value) Value {
 		panic(fmt.Sprintf("rest takes only 1 parameter: %v", vals))
 	}
 
-	list := requireSexpr(vals[0], "rest takes a list")
+	// the rest of nothing, or of one value, is the empty list
+	seq := toSeq(vals[0])
+	if seq == nil {
+		return List{}
+	}
 
-	if len(list) == 0 {
-		return Nil{}
-	} else {
-		return Sexpr(list[1:]) // this will probably bite me in the ass
+	rest := more(seq)
+	if _, ok := rest.(Nil); ok {
+		return List{}
 	}
+	return rest
 }
 
 func builtin_nth(vals []Value) Value {
 
-	if len(vals) != 2 {
-		panic(fmt.Sprintf("nth takes only 2 parameters: %v", vals))
+	if len(vals) != 2 && len(vals) != 3 {
+		panic(fmt.Sprintf("nth takes 2 or 3 parameters: %v", vals))
 	}
 
-	list := requireSexpr(vals[0], "nth takes a list")
-	n := requireInt(vals[1], "nth takes an int")
+	n := int(requireInt(vals[1], "nth takes an int"))
+
+	var v Value
+	var ok bool
+	if vector, isVector := vals[0].(Vector); isVector {
+		v, ok = vector.Get(n)
+	} else if n >= 0 {
+		seq := toSeq(vals[0])
+		for ; seq != nil && n > 0; n-- {
+			seq = seq.Next()
+		}
+		if seq != nil {
+			v, ok = seq.First(), true
+		}
+	}
 
-	return list[n]
+	if ok {
+		return v
+	} else if len(vals) == 3 {
+		return vals[2]
+	} else {
+		panic(fmt.Sprintf("index %v out of bounds for %v", vals[1], vals[0]))
+	}
 }
 
 func builtin_cons(vals []Value) Value {
@@ -130,21 +234,19 @@ func builtin_cons(vals []Value) Value {
 
 	x := vals[0]
 
-	var list Sexpr
 	switch y := vals[1].(type) {
-	case Sexpr:
-		list = y
-	case Nil:
-		list = Sexpr([]Value{})
+	case List:
+		return y.Cons(x)
+	case *LazySeq:
+		return Cons{x, y} // without realizing it
+	case Seqable:
+		if seq := y.Seq(); seq != nil {
+			return Cons{x, seq}
+		}
+		return NewList(x)
 	default:
-		panic(fmt.Sprintf("second argument must be a list or nil: %v", y))
+		panic(fmt.Sprintf("second argument must be a sequence or nil: %v", y))
 	}
-
-	newList := make([]Value, 0, len(list)+1)
-	newList = append(newList, x)
-	newList = append(newList, list...)
-
-	return Sexpr(newList)
 }
 
 func builtin_count(vals []Value) Value {
@@ -153,13 +255,9 @@ func builtin_count(vals []Value) Value {
 		panic(fmt.Sprintf("count takes only 1 parameters: %v", vals))
 	}
 
-	x := vals[0]
-
-	switch x := x.(type) {
-	case Sexpr:
-		return Int(len(x))
-	case HashMap:
-		return Int(len(x))
+	switch x := vals[0].(type) {
+	case Seqable:
+		return Int(x.Count())
 	default:
 		panic(fmt.Sprintf("count requires a collection: %v", vals))
 	}
@@ -168,215 +266,1286 @@ func builtin_count(vals []Value) Value {
 func builtin_println(vals []Value) Value {
 	newList := make([]string, 0, len(vals))
 	for _, v := range vals {
-		newList = append(newList, v.prn())
+		newList = append(newList, display(v))
 	}
 	fmt.Println(strings.Join(newList, " "))
 	return Nil{}
 }
 
-type HashMap map[Value]Value
+func builtin_hashmap(vals []Value) Value {
+
+	if math.Mod(float64(len(vals)), 2) != 0 {
+		panic(fmt.Sprintf("hash-map's arguments must be an even number of values: %v", vals))
+	}
 
-func (v HashMap) truthy() bool {
-	return len(v) > 0
+	return NewHashMap(vals...)
 }
 
-func (v HashMap) prn() string {
+func builtin_get(vals []Value) Value {
+
+	if len(vals) != 2 && len(vals) != 3 {
+		panic(fmt.Sprintf("get takes 2 or 3 parameters: %v", vals))
+	}
 
-	var items string
+	if v, ok := lookup(vals[0], vals[1]); ok {
+		return v
+	} else if len(vals) == 3 {
+		return vals[2]
+	} else {
+		return Nil{}
+	}
+}
 
-	if len(v) > 0 {
-		kvs := make([]string, 0, len(v)+2)
-		for k, val := range v {
-			kvs = append(kvs, k.prn(), val.prn())
+// Looks a key up in a map, an element up in a set, or an index up in a
+// vector.
+func lookup(coll, k Value) (Value, bool) {
+	switch coll := coll.(type) {
+	case Nil:
+		return nil, false
+	case Vector:
+		if i, ok := k.(Int); ok {
+			return coll.Get(int(i))
 		}
-		items = " " + strings.Join(kvs, " ")
+		return nil, false
+	case HashMap:
+		return coll.Get(k)
+	case SortedMap:
+		return coll.Get(k)
+	case Set:
+		return coll.Get(k)
+	case SortedSet:
+		return coll.Get(k)
+	default:
+		panic(fmt.Sprintf("first argument must be a map, set or vector: %v", coll))
+	}
+}
+
+func builtin_put(vals []Value) Value {
+
+	if len(vals) != 3 {
+		panic(fmt.Sprintf("put takes 3 parameters: %v", vals))
 	}
 
-	return "(hash-map" + items + ")"
+	m := requireHashMap(vals[0], "first argument must be a map")
+
+	return m.Assoc(vals[1], vals[2])
 }
 
-func (v HashMap) String() string {
-	return v.prn()
+// The functions that update maps work on hash maps and sorted maps alike,
+// and treat nil as an empty hash map.
+func mapAssoc(m, k, v Value) Value {
+	switch m := m.(type) {
+	case Nil:
+		return NewHashMap(k, v)
+	case SortedMap:
+		return m.Assoc(k, v)
+	default:
+		return requireHashMap(m, "first argument must be a map").Assoc(k, v)
+	}
 }
 
-func builtin_hashmap(vals []Value) Value {
+func mapDissoc(m, k Value) Value {
+	switch m := m.(type) {
+	case Nil:
+		return m
+	case SortedMap:
+		return m.Dissoc(k)
+	default:
+		return requireHashMap(m, "first argument must be a map").Dissoc(k)
+	}
+}
 
-	if math.Mod(float64(len(vals)), 2) != 0 {
-		panic(fmt.Sprintf("hash-map's arguments must be an even number of values: %v", vals))
+func requireMap(v Value, msg string) Value {
+	switch v.(type) {
+	case HashMap, SortedMap:
+		return v
+	default:
+		panic(fmt.Sprintf(msg+": %v", v))
+	}
+}
+
+func builtin_assoc(vals []Value) Value {
+
+	if len(vals) < 3 || len(vals)%2 != 1 {
+		panic(fmt.Sprintf("assoc takes a map followed by pairs of keys and values: %v", vals))
 	}
 
-	kvs := map[Value]Value{}
-	for i := 0; i < len(vals); i += 2 {
-		k := vals[i]
-		val := vals[i+1]
-		kvs[k] = val
+	if v, ok := vals[0].(Vector); ok {
+		for i := 1; i < len(vals); i += 2 {
+			n := requireIndex(vals[i], v.Count(), "assoc on a vector takes an index")
+			v = v.Assoc(n, vals[i+1])
+		}
+		return v
 	}
 
-	return HashMap(kvs)
+	m := vals[0]
+	for i := 1; i < len(vals); i += 2 {
+		m = mapAssoc(m, vals[i], vals[i+1])
+	}
+	return m
 }
 
-func builtin_get(vals []Value) Value {
+func builtin_dissoc(vals []Value) Value {
 
-	if len(vals) != 2 {
-		panic(fmt.Sprintf("get takes 2 parameters: %v", vals))
+	if len(vals) < 1 {
+		panic(fmt.Sprintf("dissoc takes at least 1 parameter: %v", vals))
 	}
 
-	m := requireHashMap(vals[0], "first argument must be a map")
-
-	return m[vals[1]]
+	m := vals[0]
+	if _, ok := m.(Nil); ok {
+		return HashMap{}
+	}
+	for _, k := range vals[1:] {
+		m = mapDissoc(m, k)
+	}
+	return requireMap(m, "first argument must be a map")
 }
 
-func builtin_put(vals []Value) Value {
+func builtin_update(vals []Value) Value {
 
-	if len(vals) != 3 {
-		panic(fmt.Sprintf("get takes 3 parameters: %v", vals))
+	if len(vals) < 3 {
+		panic(fmt.Sprintf("update takes at least 3 parameters: %v", vals))
 	}
 
-	m := requireHashMap(vals[0], "first argument must be a map")
+	f := requireIFn(vals[2], "third argument must be a function")
 
-	copy := make(map[Value]Value, len(m))
-	for k, v := range m {
-		copy[k] = v
+	old, ok := lookup(vals[0], vals[1])
+	if !ok {
+		old = Nil{}
 	}
-	copy[vals[1]] = vals[2]
 
-	return HashMap(copy)
+	args := append([]Value{old}, vals[3:]...)
+	return builtin_assoc([]Value{vals[0], vals[1], call(f, args)})
 }
 
-func seq(val Value) Sexpr {
-	switch val := val.(type) {
-	case HashMap:
-		seq := make([]Value, 0, len(val)*2)
-		for k, v := range val {
-			seq = append(seq, Sexpr([]Value{k, v}))
+func builtin_merge(vals []Value) Value {
+
+	var merged Value = Nil{}
+	for _, v := range vals {
+		if _, ok := v.(Nil); ok {
+			continue
+		}
+
+		requireMap(v, "merge takes maps")
+		if _, ok := merged.(Nil); ok {
+			merged = v
+			continue
+		}
+
+		for _, e := range mapEntries(v) {
+			merged = mapAssoc(merged, e.key, e.value)
 		}
-		return Sexpr(seq)
-	case Sexpr:
-		return val
-	default:
-		panic(fmt.Sprintf("not seq-able: %v", val))
 	}
+	return merged
 }
 
-func builtin_seq(vals []Value) Value {
+func builtin_keys(vals []Value) Value {
 
 	if len(vals) != 1 {
-		panic(fmt.Sprintf("seq takes 1 parameter: %v", vals))
+		panic(fmt.Sprintf("keys takes only 1 parameter: %v", vals))
 	}
 
-	return seq(vals[0])
+	entries := mapEntries(requireMapOrNil(vals[0], "keys takes a map"))
+	if len(entries) == 0 {
+		return Nil{}
+	}
+
+	keys := make([]Value, 0, len(entries))
+	for _, e := range entries {
+		keys = append(keys, e.key)
+	}
+	return NewList(keys...)
 }
 
-func builtin_plus(vals []Value) Value {
-	var base int = 0
-	for i := range vals {
-		val := requireInt(vals[i], "arguments to '+' must be numbers")
-		base = base + int(val)
+func builtin_vals(vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("vals takes only 1 parameter: %v", vals))
+	}
+
+	entries := mapEntries(requireMapOrNil(vals[0], "vals takes a map"))
+	if len(entries) == 0 {
+		return Nil{}
+	}
+
+	values := make([]Value, 0, len(entries))
+	for _, e := range entries {
+		values = append(values, e.value)
 	}
-	return Int(base)
+	return NewList(values...)
 }
 
-func builtin_minus(vals []Value) Value {
+// nil behaves as an empty map for the functions that read maps
+func requireMapOrNil(v Value, msg string) Value {
+	if _, ok := v.(Nil); ok {
+		return HashMap{}
+	}
+	return requireMap(v, msg)
+}
 
-	if len(vals) == 0 {
-		panic(fmt.Sprintf("- takes 1 parameter: %v", vals))
+func builtin_contains(vals []Value) Value {
+
+	if len(vals) != 2 {
+		panic(fmt.Sprintf("contains? takes 2 parameters: %v", vals))
 	}
 
-	base := requireInt(vals[0], "arguments to '-' must be numbers")
-	for _, i := range vals[1:] {
-		val := requireInt(i, "arguments to '-' must be numbers")
-		base = base - val
+	switch coll := vals[0].(type) {
+	case Nil, HashMap, SortedMap, Set, SortedSet, Vector:
+		_, ok := lookup(coll, vals[1])
+		return Boolean(ok)
+	default:
+		panic(fmt.Sprintf("contains? requires a map, set or vector: %v", vals[0]))
 	}
-	return Int(base)
 }
 
-func builtin_eq(vals []Value) Value {
+func builtin_hashset(vals []Value) Value {
+	return NewSet(vals...)
+}
+
+func builtin_disj(vals []Value) Value {
 
 	if len(vals) < 1 {
-		panic(fmt.Sprintf("= takes at least 1 parameter: %v", vals))
+		panic(fmt.Sprintf("disj takes at least 1 parameter: %v", vals))
 	}
 
-	base := int(requireInt(vals[0], "arguments to '=' must be numbers"))
-	for _, i := range vals[1:] {
-		val := int(requireInt(i, "arguments to '=' must be numbers"))
-		if val != base {
-			return Boolean(false)
+	switch set := vals[0].(type) {
+	case Nil:
+		return Nil{}
+	case SortedSet:
+		for _, v := range vals[1:] {
+			set = set.Disj(v)
 		}
+		return set
+	default:
+		hashed := requireSet(set, "first argument must be a set")
+		for _, v := range vals[1:] {
+			hashed = hashed.Disj(v)
+		}
+		return hashed
 	}
-	return Boolean(true)
 }
 
-func builtin_lt(vals []Value) Value {
+// nil behaves as an empty set in the set library
+func requireSetOrNil(v Value, msg string) Set {
+	if _, ok := v.(Nil); ok {
+		return Set{}
+	}
+	return requireSet(v, msg)
+}
+
+func builtin_union(vals []Value) Value {
+
+	union := Set{}
+	for i, v := range vals {
+		set := requireSetOrNil(v, "union takes sets")
+		if i == 0 {
+			union = set
+			continue
+		}
+		for _, e := range set.elements() {
+			union = union.Conj(e)
+		}
+	}
+	return union
+}
+
+func builtin_intersection(vals []Value) Value {
 
 	if len(vals) < 1 {
-		panic(fmt.Sprintf("< takes at least 1 parameter: %v", vals))
+		panic(fmt.Sprintf("intersection takes at least 1 parameter: %v", vals))
 	}
 
-	base := int(requireInt(vals[0], "arguments to '<' must be numbers"))
-	for _, i := range vals[1:] {
-		val := int(requireInt(i, "arguments to '<' must be numbers"))
-		if base >= val {
-			return Boolean(false)
+	intersection := requireSetOrNil(vals[0], "intersection takes sets")
+	for _, v := range vals[1:] {
+		set := requireSetOrNil(v, "intersection takes sets")
+		for _, e := range intersection.elements() {
+			if !set.Contains(e) {
+				intersection = intersection.Disj(e)
+			}
 		}
-		base = val
 	}
-	return Boolean(true)
+	return intersection
 }
 
-func builtin_lteq(vals []Value) Value {
+func builtin_difference(vals []Value) Value {
 
 	if len(vals) < 1 {
-		panic(fmt.Sprintf("<= takes at least 1 parameter: %v", vals))
+		panic(fmt.Sprintf("difference takes at least 1 parameter: %v", vals))
 	}
 
-	base := int(requireInt(vals[0], "arguments to '<=' must be numbers"))
-	for _, i := range vals[1:] {
-		val := int(requireInt(i, "arguments to '<=' must be numbers"))
-		if base > val {
-			return Boolean(false)
+	difference := requireSetOrNil(vals[0], "difference takes sets")
+	for _, v := range vals[1:] {
+		set := requireSetOrNil(v, "difference takes sets")
+		for _, e := range set.elements() {
+			difference = difference.Disj(e)
 		}
-		base = val
 	}
-	return Boolean(true)
+	return difference
 }
 
-func builtin_gt(vals []Value) Value {
+func builtin_subset(vals []Value) Value {
 
-	if len(vals) < 1 {
-		panic(fmt.Sprintf("> takes at least 1 parameter: %v", vals))
+	if len(vals) != 2 {
+		panic(fmt.Sprintf("subset? takes 2 parameters: %v", vals))
 	}
 
-	base := int(requireInt(vals[0], "arguments to '>' must be numbers"))
-	for _, i := range vals[1:] {
-		val := int(requireInt(i, "arguments to '>' must be numbers"))
-		if base <= val {
+	subset := requireSetOrNil(vals[0], "subset? takes sets")
+	set := requireSetOrNil(vals[1], "subset? takes sets")
+
+	if subset.Count() > set.Count() {
+		return Boolean(false)
+	}
+	for _, e := range subset.elements() {
+		if !set.Contains(e) {
 			return Boolean(false)
 		}
 	}
 	return Boolean(true)
 }
 
-func builtin_gteq(vals []Value) Value {
+// Returns the elements of a set for which pred is truthy.
+func builtin_select(vals []Value) Value {
+
+	if len(vals) != 2 {
+		panic(fmt.Sprintf("select takes 2 parameters: %v", vals))
+	}
+
+	pred := requireIFn(vals[0], "first argument must be a function")
+	set := requireSetOrNil(vals[1], "second argument must be a set")
+
+	for _, e := range set.elements() {
+		if !call(pred, []Value{e}).truthy() {
+			set = set.Disj(e)
+		}
+	}
+	return set
+}
+
+func builtin_sorted_map(vals []Value) Value {
+
+	if len(vals)%2 != 0 {
+		panic(fmt.Sprintf("sorted-map's arguments must be an even number of values: %v", vals))
+	}
+
+	return NewSortedMap(nil, vals...)
+}
+
+func builtin_sorted_map_by(vals []Value) Value {
+
+	if len(vals)%2 != 1 {
+		panic(fmt.Sprintf("sorted-map-by takes a comparator followed by an even number of values: %v", vals))
+	}
+
+	f := requireIFn(vals[0], "first argument must be a comparator function")
+	return NewSortedMap(fnComparator(f), vals[1:]...)
+}
+
+func builtin_sorted_set(vals []Value) Value {
+	return NewSortedSet(nil, vals...)
+}
+
+func builtin_sorted_set_by(vals []Value) Value {
 
 	if len(vals) < 1 {
-		panic(fmt.Sprintf(">= takes at least 1 parameter: %v", vals))
+		panic(fmt.Sprintf("sorted-set-by takes at least 1 parameter: %v", vals))
 	}
 
-	base := int(requireInt(vals[0], "arguments to '>=' must be numbers"))
-	for _, i := range vals[1:] {
-		val := int(requireInt(i, "arguments to '>=' must be numbers"))
-		if base < val {
-			return Boolean(false)
+	f := requireIFn(vals[0], "first argument must be a comparator function")
+	return NewSortedSet(fnComparator(f), vals[1:]...)
+}
+
+func builtin_compare(vals []Value) Value {
+
+	if len(vals) != 2 {
+		panic(fmt.Sprintf("compare takes 2 parameters: %v", vals))
+	}
+
+	return Int(Compare(vals[0], vals[1]))
+}
+
+// (subseq coll test key) or (subseq coll start-test start-key end-test
+// end-key), where the tests are <, <=, > or >=.
+func sortedRange(name string, ascending bool, vals []Value) Value {
+
+	if len(vals) != 3 && len(vals) != 5 {
+		panic(fmt.Sprintf("%v takes 3 or 5 parameters: %v", name, vals))
+	}
+
+	tests := []Value{vals[1]}
+	keys := []Value{vals[2]}
+	if len(vals) == 5 {
+		tests = append(tests, vals[3])
+		keys = append(keys, vals[4])
+	}
+
+	var found []Value
+	switch coll := vals[0].(type) {
+	case SortedMap:
+		found = entryPairs(coll.subseq(ascending, tests, keys))
+	case SortedSet:
+		for _, e := range coll.m.subseq(ascending, tests, keys) {
+			found = append(found, e.key)
 		}
+	default:
+		panic(fmt.Sprintf("%v requires a sorted map or set: %v", name, vals[0]))
 	}
-	return Boolean(true)
+
+	if len(found) == 0 {
+		return Nil{}
+	}
+	return NewList(found...)
 }
 
-func builtin_str(vals []Value) Value {
+func builtin_subseq(vals []Value) Value {
+	return sortedRange("subseq", true, vals)
+}
 
-	strs := make([]string, 0)
-	for _, i := range vals {
-		strs = append(strs, i.prn())
+func builtin_rsubseq(vals []Value) Value {
+	return sortedRange("rsubseq", false, vals)
+}
+
+// Checks that v is an index no greater than max, which is usually the count
+// of the collection being indexed.
+func requireIndex(v Value, max int, msg string) int {
+	i := int(requireInt(v, msg))
+	if i < 0 || i > max {
+		panic(fmt.Sprintf("index %v out of bounds, must be from 0 to %v", i, max))
 	}
-	return Str(strings.Join(strs, ""))
+	return i
+}
+
+func builtin_vector(vals []Value) Value {
+	return NewVector(vals...)
+}
+
+// Adds to a collection wherever is cheapest: the end of a vector, or the
+// front of a list.
+func builtin_conj(vals []Value) Value {
+
+	// with nothing to conj onto, as when conj starts a reduction
+	if len(vals) == 0 {
+		return NewVector()
+	}
+
+	switch coll := vals[0].(type) {
+	case Vector:
+		for _, v := range vals[1:] {
+			coll = coll.Conj(v)
+		}
+		return coll
+	case List:
+		for _, v := range vals[1:] {
+			coll = coll.Cons(v)
+		}
+		return coll
+	case Set:
+		for _, v := range vals[1:] {
+			coll = coll.Conj(v)
+		}
+		return coll
+	case SortedSet:
+		for _, v := range vals[1:] {
+			coll = coll.Conj(v)
+		}
+		return coll
+	case HashMap, SortedMap:
+		for _, v := range vals[1:] {
+			entry := requireVector(v, "conj onto a map takes [key value] vectors")
+			if entry.Count() != 2 {
+				panic(fmt.Sprintf("conj onto a map takes [key value] vectors: %v", v))
+			}
+			k, _ := entry.Get(0)
+			x, _ := entry.Get(1)
+			coll = mapAssoc(coll, k, x)
+		}
+		return coll
+	case Sexpr:
+		return builtin_conj(append([]Value{NewList(coll...)}, vals[1:]...))
+	case Nil:
+		return builtin_conj(append([]Value{List{}}, vals[1:]...))
+	default:
+		panic(fmt.Sprintf("conj requires a vector, list, set or map: %v", vals[0]))
+	}
+}
+
+func builtin_subvec(vals []Value) Value {
+
+	if len(vals) != 2 && len(vals) != 3 {
+		panic(fmt.Sprintf("subvec takes 2 or 3 parameters: %v", vals))
+	}
+
+	v := requireVector(vals[0], "first argument must be a vector")
+	end := v.Count()
+	if len(vals) == 3 {
+		end = requireIndex(vals[2], v.Count(), "subvec takes an end index")
+	}
+	start := requireIndex(vals[1], end, "subvec takes a start index")
+
+	return NewVector(v.elements()[start:end]...)
+}
+
+// Returns the element conj would remove with pop: the last of a vector, or
+// the first of a list.
+func builtin_peek(vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("peek takes only 1 parameter: %v", vals))
+	}
+
+	switch coll := vals[0].(type) {
+	case Vector:
+		if last, ok := coll.Get(coll.Count() - 1); ok {
+			return last
+		}
+		return Nil{}
+	case List:
+		return coll.First()
+	case Sexpr:
+		return builtin_first(vals)
+	case Nil:
+		return Nil{}
+	default:
+		panic(fmt.Sprintf("peek requires a vector or list: %v", vals[0]))
+	}
+}
+
+func builtin_pop(vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("pop takes only 1 parameter: %v", vals))
+	}
+
+	switch coll := vals[0].(type) {
+	case Vector:
+		return coll.Pop()
+	case List:
+		if coll.Count() == 0 {
+			panic("can't pop an empty list")
+		}
+		return coll.Rest()
+	case Sexpr:
+		return builtin_pop([]Value{NewList(coll...)})
+	case Nil:
+		return Nil{}
+	default:
+		panic(fmt.Sprintf("pop requires a vector or list: %v", vals[0]))
+	}
+}
+
+func builtin_seq(vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("seq takes 1 parameter: %v", vals))
+	}
+
+	if seq := toSeq(vals[0]); seq != nil {
+		return seq
+	}
+	return Nil{}
+}
+
+func builtin_range(vals []Value) Value {
+
+	switch len(vals) {
+	case 0:
+		return lazyRange(Int(0), nil, Int(1))
+	case 1:
+		return lazyRange(Int(0), requireNumber(vals[0], "range takes numbers"), Int(1))
+	case 2, 3:
+		step := Value(Int(1))
+		if len(vals) == 3 {
+			step = requireNumber(vals[2], "range takes numbers")
+		}
+		start := requireNumber(vals[0], "range takes numbers")
+		end := requireNumber(vals[1], "range takes numbers")
+		return lazyRange(start, end, step)
+	default:
+		panic(fmt.Sprintf("range takes at most 3 parameters: %v", vals))
+	}
+}
+
+func builtin_iterate(vals []Value) Value {
+
+	if len(vals) != 2 {
+		panic(fmt.Sprintf("iterate takes 2 parameters: %v", vals))
+	}
+
+	f := requireIFn(vals[0], "first argument must be a function")
+	return lazyIterate(f, vals[1])
+}
+
+func builtin_repeat(vals []Value) Value {
+
+	switch len(vals) {
+	case 1:
+		return lazyRepeat(-1, vals[0])
+	case 2:
+		n := int(requireInt(vals[0], "repeat takes a count"))
+		if n < 0 {
+			n = 0
+		}
+		return lazyRepeat(n, vals[1])
+	default:
+		panic(fmt.Sprintf("repeat takes 1 or 2 parameters: %v", vals))
+	}
+}
+
+func builtin_cycle(vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("cycle takes only 1 parameter: %v", vals))
+	}
+
+	return lazyCycle(vals[0], nil)
+}
+
+func builtin_take(vals []Value) Value {
+
+	if len(vals) != 1 && len(vals) != 2 {
+		panic(fmt.Sprintf("take takes 1 or 2 parameters: %v", vals))
+	}
+
+	n := requireInt(vals[0], "take takes a count")
+	if len(vals) == 1 {
+		return taking(int(n))
+	}
+	return lazyTake(int(n), vals[1])
+}
+
+func builtin_drop(vals []Value) Value {
+
+	if len(vals) != 2 {
+		panic(fmt.Sprintf("drop takes 2 parameters: %v", vals))
+	}
+
+	n := requireInt(vals[0], "drop takes a count")
+	return lazyDrop(int(n), vals[1])
+}
+
+func builtin_take_while(vals []Value) Value {
+
+	if len(vals) != 2 {
+		panic(fmt.Sprintf("take-while takes 2 parameters: %v", vals))
+	}
+
+	pred := requireIFn(vals[0], "first argument must be a function")
+	return lazyTakeWhile(pred, vals[1])
+}
+
+func builtin_concat(vals []Value) Value {
+	return lazyConcat(vals)
+}
+
+func builtin_map(vals []Value) Value {
+
+	if len(vals) < 1 {
+		panic(fmt.Sprintf("map takes a function and any number of collections: %v", vals))
+	}
+
+	f := requireIFn(vals[0], "first argument must be a function")
+	if len(vals) == 1 {
+		return mapping(f)
+	}
+	return lazyMap(f, vals[1:])
+}
+
+func builtin_filter(vals []Value) Value {
+
+	if len(vals) != 1 && len(vals) != 2 {
+		panic(fmt.Sprintf("filter takes 1 or 2 parameters: %v", vals))
+	}
+
+	pred := requireIFn(vals[0], "first argument must be a function")
+	if len(vals) == 1 {
+		return filtering(pred)
+	}
+	return lazyFilter(pred, vals[1])
+}
+
+// (reduce f coll) or (reduce f init coll). Without an init, the first value
+// of coll is used, and an empty coll reduces to (f). f can end the reduction
+// early by returning (reduced x).
+func builtin_reduce(vals []Value) Value {
+
+	if len(vals) != 2 && len(vals) != 3 {
+		panic(fmt.Sprintf("reduce takes 2 or 3 parameters: %v", vals))
+	}
+
+	f := requireIFn(vals[0], "first argument must be a function")
+
+	// given an init, an eduction's transducer is applied to f, rather than
+	// walking the eduction as a sequence, which without one it is, so that
+	// its first value can start the reduction
+	if len(vals) == 3 {
+		if e, ok := vals[2].(Eduction); ok {
+			return transduce(applyTransducer(e.xform, completing(f)), vals[1], e.coll)
+		}
+	}
+
+	var acc Value
+	var s Seq
+	if len(vals) == 3 {
+		acc, s = vals[1], toSeq(vals[2])
+	} else {
+		s = toSeq(vals[1])
+		if s == nil {
+			return call(f, []Value{})
+		}
+		acc, s = s.First(), s.Next()
+	}
+
+	for ; s != nil; s = s.Next() {
+		acc = call(f, []Value{acc, s.First()})
+		if r, ok := acc.(Reduced); ok {
+			return r.v
+		}
+	}
+	return acc
+}
+
+func builtin_reverse(vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("reverse takes only 1 parameter: %v", vals))
+	}
+
+	reversed := List{}
+	for s := toSeq(vals[0]); s != nil; s = s.Next() {
+		reversed = reversed.Cons(s.First())
+	}
+	return reversed
+}
+
+// (sort coll) or (sort comparator coll). The sort is stable, and uses
+// compare unless given a comparator.
+func builtin_sort(vals []Value) Value {
+
+	if len(vals) != 1 && len(vals) != 2 {
+		panic(fmt.Sprintf("sort takes 1 or 2 parameters: %v", vals))
+	}
+
+	cmp := comparator(Compare)
+	if len(vals) == 2 {
+		cmp = fnComparator(requireIFn(vals[0], "first argument must be a comparator function"))
+	}
+
+	sorted := seqElements(vals[len(vals)-1])
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return cmp(sorted[i], sorted[j]) < 0
+	})
+	return NewList(sorted...)
+}
+
+// Returns a map from each value of (f x) to a vector of the xs that produce
+// it, in the order they appear in coll.
+func builtin_group_by(vals []Value) Value {
+
+	if len(vals) != 2 {
+		panic(fmt.Sprintf("group-by takes 2 parameters: %v", vals))
+	}
+
+	f := requireIFn(vals[0], "first argument must be a function")
+
+	groups := HashMap{}
+	for s := toSeq(vals[1]); s != nil; s = s.Next() {
+		k := call(f, []Value{s.First()})
+		group, ok := groups.Get(k)
+		if !ok {
+			group = Vector{}
+		}
+		groups = groups.Assoc(k, group.(Vector).Conj(s.First()))
+	}
+	return groups
+}
+
+// (partition n coll) or (partition n step coll)
+func builtin_partition(vals []Value) Value {
+
+	if len(vals) != 2 && len(vals) != 3 {
+		panic(fmt.Sprintf("partition takes 2 or 3 parameters: %v", vals))
+	}
+
+	n := int(requireInt(vals[0], "partition takes a size"))
+	step := n
+	if len(vals) == 3 {
+		step = int(requireInt(vals[1], "partition takes a step"))
+	}
+	if n < 0 || step <= 0 {
+		panic(fmt.Sprintf("partition takes a positive size and step: %v", vals))
+	}
+
+	return lazyPartition(n, step, false, vals[len(vals)-1])
+}
+
+// (partition-all n) or (partition-all n coll) or (partition-all n step coll).
+// Unlike partition, keeps a last partition with fewer than n values.
+func builtin_partition_all(vals []Value) Value {
+
+	if len(vals) < 1 || len(vals) > 3 {
+		panic(fmt.Sprintf("partition-all takes 1 to 3 parameters: %v", vals))
+	}
+
+	n := int(requireInt(vals[0], "partition-all takes a size"))
+	step := n
+	if len(vals) == 3 {
+		step = int(requireInt(vals[1], "partition-all takes a step"))
+	}
+	if n <= 0 || step <= 0 {
+		panic(fmt.Sprintf("partition-all takes a positive size and step: %v", vals))
+	}
+
+	if len(vals) == 1 {
+		return partitioningAll(n)
+	}
+	return lazyPartition(n, step, true, vals[len(vals)-1])
+}
+
+func builtin_frequencies(vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("frequencies takes only 1 parameter: %v", vals))
+	}
+
+	counts := HashMap{}
+	for s := toSeq(vals[0]); s != nil; s = s.Next() {
+		n, ok := counts.Get(s.First())
+		if !ok {
+			n = Int(0)
+		}
+		counts = counts.Assoc(s.First(), n.(Int)+1)
+	}
+	return counts
+}
+
+func builtin_distinct(vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("distinct takes only 1 parameter: %v", vals))
+	}
+
+	return lazyDistinct(vals[0], Set{})
+}
+
+func builtin_interleave(vals []Value) Value {
+	return lazyInterleave(vals)
+}
+
+// Returns the first truthy value of (pred x), or nil.
+func builtin_some(vals []Value) Value {
+
+	if len(vals) != 2 {
+		panic(fmt.Sprintf("some takes 2 parameters: %v", vals))
+	}
+
+	pred := requireIFn(vals[0], "first argument must be a function")
+	for s := toSeq(vals[1]); s != nil; s = s.Next() {
+		if v := call(pred, []Value{s.First()}); v.truthy() {
+			return v
+		}
+	}
+	return Nil{}
+}
+
+func builtin_every(vals []Value) Value {
+
+	if len(vals) != 2 {
+		panic(fmt.Sprintf("every? takes 2 parameters: %v", vals))
+	}
+
+	pred := requireIFn(vals[0], "first argument must be a function")
+	for s := toSeq(vals[1]); s != nil; s = s.Next() {
+		if !call(pred, []Value{s.First()}).truthy() {
+			return Boolean(false)
+		}
+	}
+	return Boolean(true)
+}
+
+// (apply f args* coll). Calls f with args followed by the values of coll.
+func builtin_apply(vals []Value) Value {
+
+	if len(vals) < 2 {
+		panic(fmt.Sprintf("apply takes a function and at least 1 argument: %v", vals))
+	}
+
+	f := requireIFn(vals[0], "first argument must be a function")
+	args := append([]Value{}, vals[1:len(vals)-1]...)
+	args = append(args, seqElements(vals[len(vals)-1])...)
+	return call(f, args)
+}
+
+// (partial f args*). Returns a function calling f with args followed by its
+// own arguments.
+func builtin_partial(vals []Value) Value {
+
+	if len(vals) < 1 {
+		panic(fmt.Sprintf("partial takes a function and any number of arguments: %v", vals))
+	}
+
+	f := requireIFn(vals[0], "first argument must be a function")
+	bound := vals[1:]
+	return builtin{"partial", func(args []Value) Value {
+		return call(f, append(append([]Value{}, bound...), args...))
+	}}
+}
+
+// Returns the composition of functions, which calls the last with its
+// arguments, then the one before with the result, and so on. Composing
+// transducers gives a transducer that applies the first of them first.
+func builtin_comp(vals []Value) Value {
+
+	fs := make([]IFn, 0, len(vals))
+	for _, v := range vals {
+		fs = append(fs, requireIFn(v, "comp takes functions"))
+	}
+
+	if len(fs) == 1 {
+		return vals[0]
+	}
+	if len(fs) == 0 {
+		return builtin{"identity", builtin_identity}
+	}
+	return builtin{"comp", func(args []Value) Value {
+		result := call(fs[len(fs)-1], args)
+		for i := len(fs) - 2; i >= 0; i-- {
+			result = call(fs[i], []Value{result})
+		}
+		return result
+	}}
+}
+
+// (juxt f fs*). Returns a function calling each of the functions with its
+// arguments, and returning a vector of the results.
+func builtin_juxt(vals []Value) Value {
+
+	if len(vals) < 1 {
+		panic(fmt.Sprintf("juxt takes at least 1 function: %v", vals))
+	}
+
+	fs := make([]IFn, 0, len(vals))
+	for _, v := range vals {
+		fs = append(fs, requireIFn(v, "juxt takes functions"))
+	}
+
+	return builtin{"juxt", func(args []Value) Value {
+		results := Vector{}
+		for _, f := range fs {
+			results = results.Conj(call(f, args))
+		}
+		return results
+	}}
+}
+
+// Returns a function returning the opposite truth value of f.
+func builtin_complement(vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("complement takes only 1 parameter: %v", vals))
+	}
+
+	f := requireIFn(vals[0], "first argument must be a function")
+	return builtin{"complement", func(args []Value) Value {
+		return Boolean(!call(f, args).truthy())
+	}}
+}
+
+func builtin_identity(vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("identity takes only 1 parameter: %v", vals))
+	}
+
+	return vals[0]
+}
+
+// Returns a function that takes any arguments and returns x.
+func builtin_constantly(vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("constantly takes only 1 parameter: %v", vals))
+	}
+
+	x := vals[0]
+	return builtin{"constantly", func(args []Value) Value {
+		return x
+	}}
+}
+
+// (transduce xform f coll) or (transduce xform f init coll). Without an
+// init, (f) is used.
+func builtin_transduce(vals []Value) Value {
+
+	if len(vals) != 3 && len(vals) != 4 {
+		panic(fmt.Sprintf("transduce takes 3 or 4 parameters: %v", vals))
+	}
+
+	xform := requireIFn(vals[0], "first argument must be a transducer")
+	f := requireIFn(vals[1], "second argument must be a function")
+
+	var init Value
+	if len(vals) == 4 {
+		init = vals[2]
+	} else {
+		init = call(f, []Value{})
+	}
+
+	return transduce(applyTransducer(xform, completing(f)), init, vals[len(vals)-1])
+}
+
+// (into to from) or (into to xform from). Conjes the values of from onto to.
+func builtin_into(vals []Value) Value {
+
+	if len(vals) != 2 && len(vals) != 3 {
+		panic(fmt.Sprintf("into takes 2 or 3 parameters: %v", vals))
+	}
+
+	conj := builtin{"conj", builtin_conj}
+	var rf IFn = conj
+	if len(vals) == 3 {
+		rf = applyTransducer(requireIFn(vals[1], "second argument must be a transducer"), conj)
+	}
+
+	return transduce(rf, vals[0], vals[len(vals)-1])
+}
+
+// (sequence coll) or (sequence xform coll). With a transducer, the values are
+// transformed lazily, as the sequence is walked.
+func builtin_sequence(vals []Value) Value {
+
+	if len(vals) != 1 && len(vals) != 2 {
+		panic(fmt.Sprintf("sequence takes 1 or 2 parameters: %v", vals))
+	}
+
+	if len(vals) == 2 {
+		xform := requireIFn(vals[0], "first argument must be a transducer")
+		return lazyTransduce(xform, vals[1])
+	}
+
+	if s := toSeq(vals[0]); s != nil {
+		return s
+	}
+	return List{}
+}
+
+// (eduction xform* coll)
+func builtin_eduction(vals []Value) Value {
+
+	if len(vals) < 1 {
+		panic(fmt.Sprintf("eduction takes transducers and a collection: %v", vals))
+	}
+
+	xform := requireIFn(builtin_comp(vals[:len(vals)-1]), "eduction takes transducers")
+	return Eduction{xform, vals[len(vals)-1]}
+}
+
+func builtin_reduced(vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("reduced takes only 1 parameter: %v", vals))
+	}
+
+	return Reduced{vals[0]}
+}
+
+func builtin_is_reduced(vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("reduced? takes only 1 parameter: %v", vals))
+	}
+
+	_, ok := vals[0].(Reduced)
+	return Boolean(ok)
+}
+
+// Folds an arithmetic operation over the arguments, left to right.
+func reduceNumbers(name string, op arith, base Value, vals []Value) Value {
+	for _, i := range vals {
+		val := requireNumber(i, "arguments to '"+name+"' must be numbers")
+		base = op.apply(base, val)
+	}
+	return base
+}
+
+func builtin_plus(vals []Value) Value {
+	return reduceNumbers("+", addition, Int(0), vals)
+}
+
+func builtin_times(vals []Value) Value {
+	return reduceNumbers("*", multiplication, Int(1), vals)
+}
+
+func builtin_minus(vals []Value) Value {
+
+	if len(vals) == 0 {
+		panic(fmt.Sprintf("- takes at least 1 parameter: %v", vals))
+	}
+
+	if len(vals) == 1 {
+		return reduceNumbers("-", subtraction, Int(0), vals)
+	}
+
+	base := requireNumber(vals[0], "arguments to '-' must be numbers")
+	return reduceNumbers("-", subtraction, base, vals[1:])
+}
+
+func builtin_divide(vals []Value) Value {
+
+	if len(vals) == 0 {
+		panic(fmt.Sprintf("/ takes at least 1 parameter: %v", vals))
+	}
+
+	if len(vals) == 1 {
+		return reduceNumbers("/", division, Int(1), vals)
+	}
+
+	base := requireNumber(vals[0], "arguments to '/' must be numbers")
+	return reduceNumbers("/", division, base, vals[1:])
+}
+
+// Checks that each adjacent pair of arguments compares as required.
+func compareChain(name string, vals []Value, test func(int) bool) Value {
+
+	if len(vals) < 1 {
+		panic(fmt.Sprintf("%v takes at least 1 parameter: %v", name, vals))
+	}
+
+	base := requireNumber(vals[0], "arguments to '"+name+"' must be numbers")
+	for _, i := range vals[1:] {
+		val := requireNumber(i, "arguments to '"+name+"' must be numbers")
+		if c, ok := compareNumbers(base, val); !ok || !test(c) {
+			return Boolean(false)
+		}
+		base = val
+	}
+	return Boolean(true)
+}
+
+func builtin_eq(vals []Value) Value {
+
+	if len(vals) < 1 {
+		panic(fmt.Sprintf("= takes at least 1 parameter: %v", vals))
+	}
+
+	for _, val := range vals[1:] {
+		if !Equal(vals[0], val) {
+			return Boolean(false)
+		}
+	}
+	return Boolean(true)
+}
+
+func builtin_not_eq(vals []Value) Value {
+
+	if len(vals) < 1 {
+		panic(fmt.Sprintf("not= takes at least 1 parameter: %v", vals))
+	}
+
+	return !builtin_eq(vals).(Boolean)
+}
+
+func builtin_hash(vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("hash takes only 1 parameter: %v", vals))
+	}
+
+	return Int(Hash(vals[0]))
+}
+
+func builtin_numeq(vals []Value) Value {
+	return compareChain("==", vals, func(c int) bool { return c == 0 })
+}
+
+func builtin_lt(vals []Value) Value {
+	return compareChain("<", vals, func(c int) bool { return c < 0 })
+}
+
+func builtin_lteq(vals []Value) Value {
+	return compareChain("<=", vals, func(c int) bool { return c <= 0 })
+}
+
+func builtin_gt(vals []Value) Value {
+	return compareChain(">", vals, func(c int) bool { return c > 0 })
+}
+
+func builtin_gteq(vals []Value) Value {
+	return compareChain(">=", vals, func(c int) bool { return c >= 0 })
+}
+
+func builtin_str(vals []Value) Value {
+
+	strs := make([]string, 0)
+	for _, i := range vals {
+		strs = append(strs, display(i))
+	}
+	return Str(strings.Join(strs, ""))
+}
+
+func builtin_gensym(vals []Value) Value {
+
+	if len(vals) > 1 {
+		panic(fmt.Sprintf("gensym takes at most 1 parameter: %v", vals))
+	}
+
+	prefix := "G__"
+	if len(vals) == 1 {
+		prefix = display(vals[0])
+	}
+	return gensym(prefix)
+}
+
+// (ex-info msg data) or (ex-info msg data cause)
+func builtin_ex_info(vals []Value) Value {
+
+	if len(vals) != 2 && len(vals) != 3 {
+		panic(fmt.Sprintf("ex-info takes 2 or 3 parameters: %v", vals))
+	}
+
+	msg := requireStr(vals[0], "ex-info takes a message string")
+	data := requireMapOrNil(vals[1], "ex-info takes a map of data")
+
+	var cause Value = Nil{}
+	if len(vals) == 3 {
+		if _, ok := vals[2].(Nil); !ok {
+			cause = requireExInfo(vals[2], "ex-info's cause must be an exception")
+		}
+	}
+
+	return ExInfo{message: string(msg), data: data, cause: cause}
+}
+
+func builtin_ex_message(vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("ex-message takes only 1 parameter: %v", vals))
+	}
+
+	if e, ok := vals[0].(ExInfo); ok {
+		return Str(e.message)
+	}
+	return Nil{}
+}
+
+func builtin_ex_data(vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("ex-data takes only 1 parameter: %v", vals))
+	}
+
+	if e, ok := vals[0].(ExInfo); ok {
+		return e.data
+	}
+	return Nil{}
+}
+
+func builtin_ex_cause(vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("ex-cause takes only 1 parameter: %v", vals))
+	}
+
+	if e, ok := vals[0].(ExInfo); ok {
+		return e.cause
+	}
+	return Nil{}
 }
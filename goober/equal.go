@@ -0,0 +1,241 @@
+package goober
+
+import "fmt"
+import "hash/fnv"
+import "math"
+import "reflect"
+
+// Equality and hashing, defined over every kind of value. Whatever needs to
+// compare values or use them as keys goes through Equal and Hash, and the
+// two always agree: values that are Equal have the same Hash.
+//
+// Lists and vectors are equal when they hold equal elements in the same
+// order, maps when they hold equal keys mapped to equal values, and sets
+// when they hold equal elements, whether they are hashed or sorted. Numbers
+// are equal as described by numbersEqual. Functions are only equal to
+// themselves.
+
+func Equal(a, b Value) bool {
+	switch a := a.(type) {
+	case Nil:
+		_, ok := b.(Nil)
+		return ok
+	case Boolean, Symbol, Keyword, Str:
+		return a == b
+	case Int, BigInt, Ratio, Float:
+		return isNumber(b) && numbersEqual(a, b)
+	case Sexpr, Vector, List, Seq, *LazySeq, Eduction:
+		return isSequential(b) && seqsEqual(toSeq(a), toSeq(b))
+	case HashMap, SortedMap:
+		// looked up by hash, since a sorted map may not be able to compare
+		// the other map's keys
+		am, _ := asHashMap(a)
+		bm, ok := asHashMap(b)
+		if !ok || am.Count() != bm.Count() {
+			return false
+		}
+		for _, e := range am.entries() {
+			if bv, ok := bm.Get(e.key); !ok || !Equal(e.value, bv) {
+				return false
+			}
+		}
+		return true
+	case Set, SortedSet:
+		as, _ := asSet(a)
+		bs, ok := asSet(b)
+		if !ok || as.Count() != bs.Count() {
+			return false
+		}
+		for _, e := range as.elements() {
+			if !bs.Contains(e) {
+				return false
+			}
+		}
+		return true
+	case fn:
+		b, ok := b.(fn)
+		return ok && sameFn(a, b)
+	default:
+		if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+			return false
+		}
+		return a == b
+	}
+}
+
+// Whether v is a vector or any Seq, such as a list.
+func isSequential(v Value) bool {
+	switch v.(type) {
+	case Sexpr, Vector, List, Seq, *LazySeq, Eduction:
+		return true
+	default:
+		return false
+	}
+}
+
+func asHashMap(v Value) (HashMap, bool) {
+	switch v := v.(type) {
+	case HashMap:
+		return v, true
+	case SortedMap:
+		m := HashMap{}
+		for _, e := range v.entries() {
+			m = m.Assoc(e.key, e.value)
+		}
+		return m, true
+	default:
+		return HashMap{}, false
+	}
+}
+
+func asSet(v Value) (Set, bool) {
+	switch v := v.(type) {
+	case Set:
+		return v, true
+	case SortedSet:
+		return NewSet(v.elements()...), true
+	default:
+		return Set{}, false
+	}
+}
+
+// Returns the entries of a hash or sorted map.
+func mapEntries(v Value) []mapEntry {
+	switch v := v.(type) {
+	case HashMap:
+		return v.entries()
+	case SortedMap:
+		return v.entries()
+	default:
+		return nil
+	}
+}
+
+// Returns the elements of a hash or sorted set.
+func setElements(v Value) []Value {
+	switch v := v.(type) {
+	case Set:
+		return v.elements()
+	case SortedSet:
+		return v.elements()
+	default:
+		return nil
+	}
+}
+
+// Walks two Seqs together, only as far as it takes to tell them apart, so
+// that an infinite Seq can be compared with a finite one.
+func seqsEqual(a, b Seq) bool {
+	for ; a != nil && b != nil; a, b = a.Next(), b.Next() {
+		if !Equal(a.First(), b.First()) {
+			return false
+		}
+	}
+	return a == nil && b == nil
+}
+
+// fns are copied around by value, so two copies of the same fn are
+// recognized by sharing their body and their captured bindings.
+func sameFn(a, b fn) bool {
+	return a.name == b.name &&
+		a.isMacro == b.isMacro &&
+		sameSlice(a.exprs, b.exprs) &&
+		len(a.context.bindings) == len(b.context.bindings) &&
+		(len(a.context.bindings) == 0 || &a.context.bindings[0] == &b.context.bindings[0])
+}
+
+func sameSlice(a, b []Value) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// hash seeds, so that values of different kinds that look alike, such as a
+// symbol and a string with the same name, do not hash alike
+const (
+	hashNil uint32 = iota + 0x9e3779b9
+	hashTrue
+	hashFalse
+	hashSymbol
+	hashKeyword
+	hashStr
+	hashRatio
+	hashFloat
+	hashSequential
+	hashMap
+	hashSet
+	hashFn
+)
+
+func Hash(v Value) uint32 {
+	switch v := v.(type) {
+	case Nil:
+		return hashNil
+	case Boolean:
+		if v {
+			return hashTrue
+		}
+		return hashFalse
+	case Symbol:
+		return hashString(hashSymbol, string(v))
+	case Keyword:
+		return hashString(hashKeyword, string(v))
+	case Str:
+		return hashString(hashStr, string(v))
+	case Int:
+		return hashInt(int64(v))
+	case BigInt:
+		if v.v.IsInt64() {
+			return hashInt(v.v.Int64()) // so that 1N hashes like 1
+		}
+		return hashString(0, v.v.String())
+	case Ratio:
+		return hashString(hashRatio, v.v.String())
+	case Float:
+		f := float64(v)
+		if f == 0 {
+			f = 0 // so that -0.0 hashes like 0.0
+		}
+		return mix(hashFloat ^ hashInt(int64(math.Float64bits(f))))
+	case Sexpr, Vector, List, Seq, *LazySeq, Eduction:
+		h := hashSequential
+		for s := toSeq(v); s != nil; s = s.Next() {
+			h = 31*h + Hash(s.First())
+		}
+		return mix(h)
+	case HashMap, SortedMap:
+		h := hashMap
+		for _, e := range mapEntries(v) {
+			h += Hash(e.key) ^ mix(Hash(e.value))
+		}
+		return mix(h)
+	case Set, SortedSet:
+		h := hashSet
+		for _, e := range setElements(v) {
+			h += Hash(e)
+		}
+		return mix(h)
+	case fn:
+		return hashString(hashFn, v.name) ^ uint32(len(v.exprs))
+	default:
+		return hashString(0, fmt.Sprintf("%T:%v", v, v.prn()))
+	}
+}
+
+func hashString(seed uint32, s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return mix(seed ^ h.Sum32())
+}
+
+func hashInt(i int64) uint32 {
+	return mix(uint32(i) ^ mix(uint32(i>>32)))
+}
+
+// the murmur3 finalizer, to spread similar inputs across all the bits
+func mix(h uint32) uint32 {
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
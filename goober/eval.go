@@ -3,6 +3,8 @@ package goober
 import "math"
 import "fmt"
 import "strings"
+import "strconv"
+import "io"
 
 // incorporate functions as value types
 
@@ -16,6 +18,15 @@ type fn struct {
 
 type recur []Value
 
+// A call to a fn in tail position, which is returned rather than made, so
+// that special_fn_call can make it once the call it is the tail of has
+// returned, without growing the stack.
+type tailCall struct {
+	f    fn
+	args []Value
+	pos  Pos
+}
+
 func (v fn) truthy() bool {
 	return true
 }
@@ -50,15 +61,27 @@ func (v recur) prn() string {
 	return fmt.Sprintf("#recur[%v]", v)
 }
 
+func (v tailCall) truthy() bool {
+	return true
+}
+
+func (v tailCall) prn() string {
+	return fmt.Sprintf("#tail-call[%v %v]", v.f.displayName(), Sexpr(v.args))
+}
+
 // data structures to support vars and bindings
 
 type Ns struct {
 	Name string
 	vars map[string]Value
+
+	TraceWriter io.Writer // where traces are logged, os.Stderr if nil
+	traceAll    bool
+	traced      map[string]bool // the names of the functions traced
 }
 
 func NewNs(name string) Ns {
-	return Ns{Name: "user", vars: map[string]Value{}}
+	return Ns{Name: name, vars: map[string]Value{}}
 }
 
 func (ns *Ns) def(name string, value Value) {
@@ -77,6 +100,7 @@ type binding struct {
 type context struct {
 	ns       *Ns
 	bindings []binding
+	recur    recurTarget // what a recur in tail position jumps back to, if anything
 }
 
 func (c *context) push(name Symbol, value Value) {
@@ -100,6 +124,22 @@ func (c context) get(name Symbol) Value {
 
 	s := string(name)
 
+	if isQualified(name) {
+		i := strings.Index(s, "/")
+		if s[:i] != c.ns.Name {
+			panic(fmt.Sprintf("no such namespace: %v", s[:i]))
+		}
+		s = s[i+1:]
+
+		if v, ok := c.ns.vars[s]; ok {
+			return v
+		}
+		if b, ok := builtinMap[s]; ok {
+			return b.(Value)
+		}
+		panic("cannot find a var with this symbol name: " + name)
+	}
+
 	if len(c.bindings) > 0 {
 		for i := len(c.bindings); i > 0; i-- {
 			binding := c.bindings[i-1]
@@ -149,6 +189,39 @@ func requireSexpr(v Value, msg string) Sexpr {
 	}
 }
 
+// Binding forms, such as the bindings of a let or the arguments of a fn,
+// may be written as either a list or a vector.
+func requireBindings(v Value, msg string) []Value {
+	switch x := v.(type) {
+	case Sexpr:
+		return x
+	case Vector:
+		return x.elements()
+	case Seq: // built by a macro
+		return seqElements(x)
+	default:
+		panic(fmt.Sprintf(msg+": %v", v))
+	}
+}
+
+func requireVector(v Value, msg string) Vector {
+	switch x := v.(type) {
+	case Vector:
+		return x
+	default:
+		panic(fmt.Sprintf(msg+": %v", v))
+	}
+}
+
+func requireSet(v Value, msg string) Set {
+	switch x := v.(type) {
+	case Set:
+		return x
+	default:
+		panic(fmt.Sprintf(msg+": %v", v))
+	}
+}
+
 func requireKeyword(v Value, msg string) Keyword {
 	switch x := v.(type) {
 	case Keyword:
@@ -167,6 +240,33 @@ func requireHashMap(v Value, msg string) HashMap {
 	}
 }
 
+func requireStr(v Value, msg string) Str {
+	switch x := v.(type) {
+	case Str:
+		return x
+	default:
+		panic(fmt.Sprintf(msg+": %v", v))
+	}
+}
+
+func requireExInfo(v Value, msg string) ExInfo {
+	switch x := v.(type) {
+	case ExInfo:
+		return x
+	default:
+		panic(fmt.Sprintf(msg+": %v", v))
+	}
+}
+
+func requireIFn(v Value, msg string) IFn {
+	switch x := v.(type) {
+	case IFn:
+		return x
+	default:
+		panic(fmt.Sprintf(msg+": %v", v))
+	}
+}
+
 func requireFn(v Value, msg string) fn {
 	switch x := v.(type) {
 	case fn:
@@ -187,7 +287,12 @@ func special_def(context *context, vals []Value) Value {
 
 	switch varname := vals[0].(type) {
 	case Symbol:
-		context.ns.def(string(varname), eval(context, vals[1]))
+		v := eval(context, vals[1])
+		if f, ok := v.(fn); ok && isFnForm(vals[1]) {
+			f.name = string(varname) // so that it is named in stack traces
+			v = f
+		}
+		context.ns.def(string(varname), v)
 	default:
 		panic(fmt.Sprintf("vars can only be named by symbols: %v", varname))
 	}
@@ -195,13 +300,33 @@ func special_def(context *context, vals []Value) Value {
 	return Nil{}
 }
 
+// Whether v is a (fn ...) form, whose fn can take the name it is def'd to
+// without being mistaken for some other fn.
+func isFnForm(v Value) bool {
+	s, ok := v.(Sexpr)
+	return ok && len(s) > 0 && s[0] == Symbol("fn")
+}
+
+// Evaluates a form, either as eval does, or in tail position as evalTail
+// does. The special forms that have tail positions take one to evaluate the
+// forms in them with.
+type evaluator func(*context, Value) Value
+
 func special_let(context *context, vals []Value) Value {
+	return evalLet(context, vals, eval)
+}
+
+func special_let_tail(context *context, vals []Value) Value {
+	return evalLet(context, vals, evalTail)
+}
+
+func evalLet(context *context, vals []Value, evalLast evaluator) Value {
 
 	if len(vals) < 1 {
 		panic(fmt.Sprintf("let takes at least 1 parameter: %v", vals))
 	}
 
-	bindings := requireSexpr(vals[0], "vars can only be named by symbols")
+	bindings := requireBindings(vals[0], "let's bindings must be a list or vector")
 
 	if math.Mod(float64(len(bindings)), 2) != 0 {
 		panic(fmt.Sprintf("let's binding list must be an even number of values: %v", bindings))
@@ -222,20 +347,86 @@ func special_let(context *context, vals []Value) Value {
 		pushes++
 	}
 
-	// eval the rest of the let arguments
+	// eval the rest of the let arguments, returning the result of the last
 
-	var result Value
-	for i := 1; i < len(vals); i++ {
-		expr := vals[i]
-		result = eval(context, expr)
+	return evalBody(context, vals[1:], evalLast)
+}
+
+// (loop [sym expr ...] body*). Binds the symbols like let, but a recur in
+// the body evaluates it again with the symbols bound to the values recur
+// passes.
+//
+// The body is always evaluated in tail position, which is where its recurs
+// have to be, so a loop that isn't in tail position itself makes the tail
+// call its body returns, if it returns one.
+func special_loop(context *context, vals []Value) Value {
+	result := special_loop_tail(context, vals)
+	if r, ok := result.(tailCall); ok {
+		pushFrame(r.f, r.pos)
+		defer popFrame()
+		return special_fn_call(r.f.displayName(), r.f, context, r.args)
 	}
+	return result
+}
 
-	// return the result of the last statement in the let block
+func special_loop_tail(context *context, vals []Value) Value {
 
-	return result
+	if len(vals) < 1 {
+		panic(fmt.Sprintf("loop takes at least 1 parameter: %v", vals))
+	}
+
+	bindings := requireBindings(vals[0], "loop's bindings must be a list or vector")
+
+	if len(bindings)%2 != 0 {
+		panic(fmt.Sprintf("loop's binding list must be an even number of values: %v", bindings))
+	}
+
+	syms := make([]Symbol, 0, len(bindings)/2)
+	for i := 0; i < len(bindings); i += 2 {
+		syms = append(syms, requireSymbol(bindings[i], "bindings can only be made for symbols"))
+	}
+
+	body := vals[1:]
+	target := recurTarget{name: "loop", arity: len(syms)}
+	checkRecur(context, body, target)
+
+	// each binding sees the ones before it, as with let
+	for i, sym := range syms {
+		context.push(sym, eval(context, bindings[2*i+1]))
+		defer context.pop()
+	}
+
+	defer func(outer recurTarget) { context.recur = outer }(context.recur)
+	context.recur = target
+
+	for {
+		result := evalBody(context, body, evalTail)
+
+		r, ok := result.(recur)
+		if !ok {
+			return result
+		}
+		if len(r) != len(syms) {
+			panic(fmt.Sprintf("recur passes %v values to a loop that takes %v: %v", len(r), len(syms), r))
+		}
+
+		// rebound in place, so the deferred pops still pop these bindings
+		base := len(context.bindings) - len(syms)
+		for i, v := range r {
+			context.bindings[base+i].value = v
+		}
+	}
 }
 
 func special_if(context *context, vals []Value) Value {
+	return evalIf(context, vals, eval)
+}
+
+func special_if_tail(context *context, vals []Value) Value {
+	return evalIf(context, vals, evalTail)
+}
+
+func evalIf(context *context, vals []Value, evalBranch evaluator) Value {
 
 	if len(vals) < 2 {
 		panic(fmt.Sprintf("if takes at least 2 parameters: %v", vals))
@@ -250,12 +441,12 @@ func special_if(context *context, vals []Value) Value {
 	switch v := test.(type) {
 	case Value:
 		if v.truthy() {
-			return eval(context, vals[1])
+			return evalBranch(context, vals[1])
 		} else {
 			if len(vals) == 2 {
 				return Nil{}
 			} else {
-				return eval(context, vals[2])
+				return evalBranch(context, vals[2])
 			}
 		}
 	default:
@@ -271,7 +462,7 @@ type argsInfo struct {
 
 func getArgs(args Value) argsInfo {
 
-	params := requireSexpr(args, "expected args in the form of a list")
+	params := requireBindings(args, "expected args in the form of a list or vector")
 	result := argsInfo{}
 
 	result.declared = make([]Symbol, 0, len(params))
@@ -299,8 +490,10 @@ func special_fn(context *context, vals []Value) Value {
 		panic(fmt.Sprintf("fn takes at least 2 parameters: %v", vals))
 	}
 
-	// intentionally copying the context here, that becomes part of the fn
-	return fn{args: getArgs(vals[0]), exprs: vals[1:], context: *context}
+	args := getArgs(vals[0])
+	checkRecur(context, vals[1:], args.recurTarget())
+
+	return fn{args: args, exprs: vals[1:], context: capture(context)}
 }
 
 func special_defmacro(context *context, vals []Value) Value {
@@ -309,11 +502,14 @@ func special_defmacro(context *context, vals []Value) Value {
 		panic(fmt.Sprintf("defmacro takes 2 parameters: %v", vals))
 	}
 
-	// intentionally copying the context here, that becomes part of the fn
-	f := fn{args: getArgs(vals[1]), exprs: vals[2:], context: *context, isMacro: true}
+	args := getArgs(vals[1])
+	checkRecur(context, vals[2:], args.recurTarget())
+
+	f := fn{args: args, exprs: vals[2:], context: capture(context), isMacro: true}
 
 	switch varname := vals[0].(type) {
 	case Symbol:
+		f.name = string(varname)
 		context.ns.def(string(varname), f)
 	default:
 		panic(fmt.Sprintf("vars can only be named by symbols: %v", varname))
@@ -344,8 +540,6 @@ func packageArgs(name string, fn *fn, supplied []Value) ([]Value, []Value) { //
 
 func special_fn_call_inner(name string, fn *fn, context *context, vals []Value) Value {
 
-	//fmt.Printf("calling %v with args %v\n", fn, Sexpr(vals))
-
 	declared, rest := packageArgs(name, fn, vals)
 
 	for i, bindingName := range fn.args.declared {
@@ -361,21 +555,15 @@ func special_fn_call_inner(name string, fn *fn, context *context, vals []Value)
 		if len(rest) == 0 {
 			bindingValue = Nil{}
 		} else {
-			bindingValue = Sexpr(rest)
+			bindingValue = NewList(rest...)
 		}
 
 		fn.context.push(bindingName, bindingValue)
 		defer fn.context.pop()
 	}
 
-	var result Value
-	for _, expr := range fn.exprs {
-		result = eval(&fn.context, expr)
-	}
-
-	//fmt.Printf("DONE calling %v with args %v => %v\n", fn, Sexpr(vals), result)
-
-	return result
+	fn.context.recur = fn.args.recurTarget()
+	return evalBody(&fn.context, fn.exprs, evalTail)
 }
 
 func special_fn_call(name string, fn fn, context *context, vals []Value) Value {
@@ -385,6 +573,10 @@ func special_fn_call(name string, fn fn, context *context, vals []Value) Value {
 		switch r := result.(type) {
 		case recur:
 			result = special_fn_call_inner(name, &fn, context, r)
+		case tailCall:
+			fn, name = r.f, r.f.displayName()
+			replaceFrame(r.f, r.pos)
+			result = special_fn_call_inner(name, &fn, context, r.args)
 		default:
 			return result
 		}
@@ -394,24 +586,48 @@ func special_fn_call(name string, fn fn, context *context, vals []Value) Value {
 
 func special_keyword_call(context *context, k Keyword, args []Value) Value {
 
-	if len(args) != 1 {
-		panic(fmt.Sprintf("a keyword as a function takes only one argument: %v", args))
+	if len(args) != 1 && len(args) != 2 {
+		panic(fmt.Sprintf("a keyword as a function takes one or two arguments: %v", args))
 	}
 
-	replacement := Sexpr([]Value{
-		Symbol("get"),
-		args[0],
-		k,
-	})
+	// the arguments are already evaluated, so look the keyword up directly
+	// rather than evaluating a call to get
+	return builtin_get(append([]Value{args[0], k}, args[1:]...))
+}
 
-	return eval(context, replacement)
+// A set called as a function looks up its argument, returning the element
+// of the set equal to it, or nil.
+func special_set_call(context *context, s Value, args []Value) Value {
+
+	if len(args) != 1 {
+		panic(fmt.Sprintf("a set as a function takes only one argument: %v", args))
+	}
+
+	if v, ok := lookup(s, args[0]); ok {
+		return v
+	}
+	return Nil{}
 }
 
 func special_do(context *context, vals []Value) Value {
+	return evalBody(context, vals, eval)
+}
+
+func special_do_tail(context *context, vals []Value) Value {
+	return evalBody(context, vals, evalTail)
+}
+
+// Evaluates forms in order, returning the value of the last, which is
+// evaluated with evalLast.
+func evalBody(context *context, vals []Value, evalLast evaluator) Value {
 
 	var result Value
-	for _, expr := range vals {
-		result = eval(context, expr)
+	for i, expr := range vals {
+		if i == len(vals)-1 {
+			result = evalLast(context, expr)
+		} else {
+			result = eval(context, expr)
+		}
 	}
 
 	return result
@@ -425,6 +641,91 @@ func special_quote(context *context, vals []Value) Value {
 	return param
 }
 
+// Returns a copy of a context for a fn or lazy-seq to evaluate its body in,
+// maybe long after the context has moved on. The bindings are copied too,
+// since by then they may have been popped and their slots reused, or been
+// rebound by a loop's recur.
+func capture(context *context) context {
+	captured := *context
+	captured.bindings = append([]binding(nil), context.bindings...)
+	return captured
+}
+
+// Returns a lazy sequence of the values of the body, which is evaluated the
+// first time the sequence is walked.
+func special_lazy_seq(context *context, vals []Value) Value {
+
+	captured := capture(context)
+
+	return newLazySeq(func() Value {
+		return special_do(&captured, vals)
+	})
+}
+
+func special_throw(context *context, vals []Value) Value {
+
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("throw takes only 1 parameter: %v", vals))
+	}
+
+	panic(requireExInfo(eval(context, vals[0]), "throw takes an exception made by ex-info"))
+}
+
+// (try expr* (catch e expr*) (finally expr*)). The catch and finally clauses
+// are both optional. If evaluating the body throws, or goober fails with an
+// error of its own, the catch clause is evaluated with the exception bound to
+// its symbol. The finally clause is always evaluated last, for its side
+// effects.
+func special_try(context *context, vals []Value) Value {
+
+	body := vals
+	var catch, finally Sexpr
+
+	for len(body) > 0 {
+		clause, ok := body[len(body)-1].(Sexpr)
+		if !ok || len(clause) == 0 {
+			break
+		}
+		if clause[0] == Symbol("finally") && finally == nil && catch == nil {
+			finally = clause
+		} else if clause[0] == Symbol("catch") && catch == nil {
+			catch = clause
+		} else {
+			break
+		}
+		body = body[:len(body)-1]
+	}
+
+	if finally != nil {
+		defer special_do(context, finally[1:])
+	}
+
+	if catch == nil {
+		return special_do(context, body)
+	}
+
+	if len(catch) < 2 {
+		panic(fmt.Sprintf("catch takes a symbol to bind the exception to: %v", catch))
+	}
+	sym := requireSymbol(catch[1], "catch takes a symbol to bind the exception to")
+	return tryCatch(context, body, sym, catch[2:])
+}
+
+func tryCatch(context *context, body []Value, sym Symbol, handler []Value) (result Value) {
+
+	// bindings pushed in the body are popped as the panic unwinds, so the
+	// context is back as it was by the time this runs
+	defer func() {
+		if e := recover(); e != nil {
+			context.push(sym, toException(e))
+			defer context.pop()
+			result = special_do(context, handler)
+		}
+	}()
+
+	return special_do(context, body)
+}
+
 func special_recur(context *context, vals []Value) Value {
 	return recur(evalAll(context, vals))
 }
@@ -438,7 +739,122 @@ func evalRest(context *context, v Sexpr) []Value {
 	return rest
 }
 
-// TODO: macro syntax quotes
+// Expands a syntax-quoted template. Symbols in the template are qualified
+// with the namespace, so that a macro's expansion refers to the vars the
+// macro meant whatever is bound where it is used. Symbols ending in # are
+// replaced with generated names, the same one for each use within the
+// template. Unquoted forms are evaluated, and unquote-spliced forms are
+// evaluated and their elements spliced into the enclosing collection.
+func special_syntax_quote(context *context, vals []Value) Value {
+	if len(vals) != 1 {
+		panic(fmt.Sprintf("syntax-quote takes only 1 parameter: %v", vals))
+	}
+	return syntaxQuote(context, map[Symbol]Symbol{}, vals[0], 1)
+}
+
+func special_unquote(context *context, vals []Value) Value {
+	panic(fmt.Sprintf("unquote used outside of a syntax-quote: %v", Sexpr(vals)))
+}
+
+// Returns the form that a reader shorthand such as ~ wraps, if the value is
+// such a form.
+func unwrap(v Value, wrapper Symbol) (Value, bool) {
+	if list, ok := v.(Sexpr); ok && len(list) == 2 && list[0] == wrapper {
+		return list[1], true
+	}
+	return nil, false
+}
+
+// Expands a syntax-quoted form. depth is how many syntax-quotes the form is
+// nested in, and only an unquote in just one is evaluated; one nested
+// deeper is left for the syntax-quote it belongs to, when that is evaluated.
+func syntaxQuote(context *context, gensyms map[Symbol]Symbol, v Value, depth int) Value {
+	switch v := v.(type) {
+	case Symbol:
+		return qualify(context, gensyms, v)
+	case Sexpr:
+		if unquoted, ok := unwrap(v, Symbol("unquote")); ok {
+			if depth == 1 {
+				return eval(context, unquoted)
+			}
+			return Sexpr{v[0], syntaxQuote(context, gensyms, unquoted, depth-1)}
+		}
+		if spliced, ok := unwrap(v, Symbol("unquote-splicing")); ok {
+			if depth == 1 {
+				panic(fmt.Sprintf("unquote-splicing used outside of a list or vector: %v", v))
+			}
+			return Sexpr{v[0], syntaxQuote(context, gensyms, spliced, depth-1)}
+		}
+		if quoted, ok := unwrap(v, Symbol("syntax-quote")); ok {
+			return Sexpr{v[0], syntaxQuote(context, gensyms, quoted, depth+1)}
+		}
+		return Sexpr(syntaxQuoteAll(context, gensyms, v, depth))
+	case Vector:
+		return NewVector(syntaxQuoteAll(context, gensyms, v.elements(), depth)...)
+	case HashMap:
+		m := HashMap{}
+		for _, e := range v.entries() {
+			m = m.Assoc(syntaxQuote(context, gensyms, e.key, depth), syntaxQuote(context, gensyms, e.value, depth))
+		}
+		return m
+	case Set:
+		set := Set{}
+		for _, e := range v.elements() {
+			set = set.Conj(syntaxQuote(context, gensyms, e, depth))
+		}
+		return set
+	default:
+		return v
+	}
+}
+
+func syntaxQuoteAll(context *context, gensyms map[Symbol]Symbol, vals []Value, depth int) []Value {
+	expanded := make([]Value, 0, len(vals))
+	for _, v := range vals {
+		if spliced, ok := unwrap(v, Symbol("unquote-splicing")); ok && depth == 1 {
+			switch spliced := eval(context, spliced).(type) {
+			case Nil:
+			default:
+				expanded = append(expanded, seqElements(spliced)...)
+			}
+		} else {
+			expanded = append(expanded, syntaxQuote(context, gensyms, v, depth))
+		}
+	}
+	return expanded
+}
+
+func qualify(context *context, gensyms map[Symbol]Symbol, s Symbol) Symbol {
+	name := string(s)
+
+	// catch and finally aren't specials, but are part of try's syntax
+	if _, ok := specials[name]; ok || name == "&" || name == "catch" || name == "finally" || isQualified(s) {
+		return s
+	}
+
+	if strings.HasSuffix(name, "#") && len(name) > 1 {
+		if _, ok := gensyms[s]; !ok {
+			gensyms[s] = gensym(name[:len(name)-1]+"__") + "__auto__"
+		}
+		return gensyms[s]
+	}
+
+	return Symbol(context.ns.Name + "/" + name)
+}
+
+func isQualified(s Symbol) bool {
+	i := strings.Index(string(s), "/")
+	return i > 0 && i < len(s)-1
+}
+
+var gensymCounter = 0
+
+// Generates a symbol that will not collide with any symbol a user would
+// write.
+func gensym(prefix string) Symbol {
+	gensymCounter++
+	return Symbol(prefix + strconv.Itoa(gensymCounter))
+}
 
 type IFn interface {
 	Name() string
@@ -462,19 +878,51 @@ func (f fn) IsMacro() bool {
 	return f.isMacro
 }
 
-func (f fn) Invoke(context *context, args []Value) Value {
-	var name string
+func (f fn) displayName() string {
 	if f.name == "" {
-		name = "#<anonymous>"
-	} else {
-		name = f.name
+		return "#<anonymous>"
 	}
+	return f.name
+}
 
+func (f fn) Invoke(context *context, args []Value) Value {
 	if !f.isMacro {
 		args = evalAll(context, args)
 	}
 
-	return special_fn_call(name, f, context, args)
+	return special_fn_call(f.displayName(), f, context, args)
+}
+
+// Calls a function with arguments that have already been evaluated. This is
+// how builtins call the functions they are handed.
+func call(f IFn, args []Value) Value {
+	pushFrame(f, Pos{})
+	defer popFrame()
+
+	if f, ok := f.(fn); ok && f.context.ns.tracing(f) {
+		return traceCall(f.context.ns, f, args)
+	}
+	return callEvaluated(f, args)
+}
+
+func callEvaluated(f IFn, args []Value) Value {
+	switch f := f.(type) {
+	case fn:
+		if f.isMacro {
+			panic(fmt.Sprintf("cannot call a macro as a function: %v", f.displayName()))
+		}
+		return special_fn_call(f.displayName(), f, nil, args)
+	case builtin:
+		return f.f(args)
+	case Keyword:
+		return special_keyword_call(nil, f, args)
+	case Set:
+		return special_set_call(nil, f, args)
+	case SortedSet:
+		return special_set_call(nil, f, args)
+	default:
+		panic(fmt.Sprintf("%v cannot be called as a function", f.Name()))
+	}
 }
 
 func (f Keyword) Name() string {
@@ -489,11 +937,36 @@ func (f Keyword) Invoke(context *context, args []Value) Value {
 	return special_keyword_call(context, f, evalAll(context, args))
 }
 
+func (f Set) Name() string {
+	return f.prn()
+}
+
+func (f Set) IsMacro() bool {
+	return false
+}
+
+func (f Set) Invoke(context *context, args []Value) Value {
+	return special_set_call(context, f, evalAll(context, args))
+}
+
+func (f SortedSet) Name() string {
+	return f.prn()
+}
+
+func (f SortedSet) IsMacro() bool {
+	return false
+}
+
+func (f SortedSet) Invoke(context *context, args []Value) Value {
+	return special_set_call(context, f, evalAll(context, args))
+}
+
 type special_f func(*context, []Value) Value
 
 type special struct {
 	name string
 	f    special_f
+	tail special_f // used instead of f in tail position, if there is one
 }
 
 func (f special) Name() string {
@@ -512,6 +985,38 @@ func makeSpecial(name string, f func(*context, []Value) Value) IFn {
 	return special{name: name, f: special_f(f)}
 }
 
+// Makes a special form whose tail positions are tail positions of the form
+// itself, evaluated by tail rather than f when the form is in tail position.
+func makeTailSpecial(name string, f, tail func(*context, []Value) Value) IFn {
+	return special{name: name, f: special_f(f), tail: special_f(tail)}
+}
+
+var specials map[string]IFn
+
+// populated here rather than in the declaration, since the special functions
+// refer back to eval, which refers to this map
+func init() {
+	specials = map[string]IFn{
+		"def":              makeSpecial("def", special_def),
+		"defmacro":         makeSpecial("defmacro", special_defmacro),
+		"let":              makeTailSpecial("let", special_let, special_let_tail),
+		"loop":             makeTailSpecial("loop", special_loop, special_loop_tail),
+		"if":               makeTailSpecial("if", special_if, special_if_tail),
+		"fn":               makeSpecial("fn", special_fn),
+		"quote":            makeSpecial("quote", special_quote),
+		"syntax-quote":     makeSpecial("syntax-quote", special_syntax_quote),
+		"unquote":          makeSpecial("unquote", special_unquote),
+		"unquote-splicing": makeSpecial("unquote-splicing", special_unquote),
+		"do":               makeTailSpecial("do", special_do, special_do_tail),
+		"recur":            makeSpecial("recur", special_recur),
+		"lazy-seq":         makeSpecial("lazy-seq", special_lazy_seq),
+		"throw":            makeSpecial("throw", special_throw),
+		"try":              makeSpecial("try", special_try),
+		"trace":            makeSpecial("trace", special_trace),
+		"untrace":          makeSpecial("untrace", special_untrace),
+	}
+}
+
 func getIFn(context *context, v Value) IFn {
 
 	switch first := v.(type) {
@@ -519,23 +1024,8 @@ func getIFn(context *context, v Value) IFn {
 		return first
 	case Symbol:
 
-		switch first { // special functions
-		case "def":
-			return makeSpecial("def", special_def)
-		case "defmacro":
-			return makeSpecial("defmacro", special_defmacro)
-		case "let":
-			return makeSpecial("let", special_let)
-		case "if":
-			return makeSpecial("if", special_if)
-		case "fn":
-			return makeSpecial("fn", special_fn)
-		case "quote":
-			return makeSpecial("quote", special_quote)
-		case "do":
-			return makeSpecial("do", special_do)
-		case "recur":
-			return makeSpecial("recur", special_recur)
+		if special, ok := specials[string(first)]; ok { // special functions
+			return special
 		}
 
 		if builtin, ok := builtinMap[string(first)]; ok { // builtin functions
@@ -549,18 +1039,23 @@ func getIFn(context *context, v Value) IFn {
 			panic(fmt.Sprintf("symbol '%v' is not bound to an IFn: %v", first, resolved))
 		}
 	default:
-		panic(fmt.Sprintf("not a valid function: %", first))
+		panic(fmt.Sprintf("not a valid function: %v", first))
 	}
 }
 
-// TODO: implement debug message mode
-// TODO: implement stack traces in reader and eval
-
 // Evaluates a Value data structure as code.
 func eval(context *context, v Value) Value {
+	return evalIn(context, v, false)
+}
 
-	//fmt.Printf("eval() input: %v\n", v)
-	//fmt.Printf("eval() bindings: %v\n", context.bindings)
+// Evaluates v in tail position, where its value is the value of the fn body
+// it is in. If it is a call to a fn, the call is returned as a tailCall,
+// which the fn's caller makes in its place.
+func evalTail(context *context, v Value) Value {
+	return evalIn(context, v, true)
+}
+
+func evalIn(context *context, v Value, tail bool) Value {
 
 	var result Value
 
@@ -574,40 +1069,122 @@ func eval(context *context, v Value) Value {
 		first := v[0]
 		rest := v[1:]
 
-		if sexpr, ok := first.(Sexpr); ok {
+		var f IFn
+		switch first.(type) {
+		case Seq:
 			resolved := make([]Value, 0)
-			resolved = append(resolved, eval(context, sexpr))
+			resolved = append(resolved, eval(context, first))
 			resolved = append(resolved, rest...)
-			return eval(context, Sexpr(resolved))
-		} else {
-			f := getIFn(context, first)
-			if f.IsMacro() {
-				expanded := f.Invoke(context, rest)
-				//fmt.Printf("expanded %v to %v\n", f, expanded)
-				result = eval(context, expanded)
+			return evalIn(context, Sexpr(resolved), tail)
+		case Set:
+			// a set literal's elements are code like any other, evaluated
+			// before the set is called
+			f = eval(context, first).(Set)
+		default:
+			f = getIFn(context, first)
+		}
+
+		if f.IsMacro() {
+			expanded := expand(context, f, rest, positionOf(v))
+			if context.ns.tracing(f) {
+				traceExpansion(context.ns, v, expanded)
+			}
+			checkRecurExpansion(context, expanded, tail)
+			result = evalIn(context, expanded, tail)
+		} else if s, ok := f.(special); ok {
+			if tail && s.tail != nil {
+				result = s.tail(context, rest)
 			} else {
-				result = f.Invoke(context, rest)
+				result = s.Invoke(context, rest)
 			}
+		} else if g, ok := f.(fn); ok && tail && !context.ns.tracing(f) {
+			result = tailCall{g, evalAll(context, rest), positionOf(v)}
+		} else {
+			result = invoke(context, f, rest, positionOf(v))
 		}
 
 	case Symbol:
 		result = context.get(v)
 
+	case Vector:
+		result = NewVector(evalAll(context, v.elements())...)
+
+	case HashMap:
+		m := HashMap{}
+		for _, e := range v.entries() {
+			m = m.Assoc(eval(context, e.key), eval(context, e.value))
+		}
+		result = m
+
+	case Set:
+		set := Set{}
+		for _, e := range v.elements() {
+			set = set.Conj(eval(context, e))
+		}
+		result = set
+
+	case Seq:
+		// a list built at runtime, usually by a macro, is evaluated as code
+		// just like the Sexpr it would have been had it been read
+		if v.Seq() == nil {
+			return v
+		}
+		result = evalIn(context, Sexpr(seqElements(v)), tail)
+
 	default:
 		result = v
 	}
 
-	//fmt.Printf("eval() output: %v\n", result)
-
 	return result
 }
 
+// Expands a macro, with a frame on the stack for the expansion.
+func expand(context *context, f IFn, args []Value, pos Pos) Value {
+	pushFrame(f, pos)
+	defer popFrame()
+	return f.Invoke(context, args)
+}
+
+// Invokes a function, with a frame on the stack for the call.
+func invoke(context *context, f IFn, args []Value, pos Pos) Value {
+	pushFrame(f, pos)
+	defer popFrame()
+
+	if context.ns.tracing(f) {
+		return traceCall(context.ns, f, evalAll(context, args))
+	}
+	return f.Invoke(context, args)
+}
+
+// Evaluates a form in a namespace. Whatever goes wrong, Eval panics with an
+// ExInfo, even for errors that happen outside of any call, like a symbol that
+// isn't bound to anything.
 func Eval(ns *Ns, v Value) Value {
 
+	defer rethrow()
+
 	context := context{
 		ns:       ns,
 		bindings: make([]binding, 0),
 	}
 
-	return eval(&context, v)
+	result := eval(&context, v)
+	if _, ok := result.(recur); ok {
+		panic(fmt.Sprintf("recur used outside of a fn or loop: %v", v))
+	}
+	return result
+}
+
+// Prints a value as it would be read, which realizes any lazy sequences in
+// it, and so like Eval, panics with an ExInfo if evaluating them fails.
+func Prn(v Value) string {
+	defer rethrow()
+	return v.prn()
+}
+
+// Deferred to re-panic with an ExInfo for whatever was panicked with.
+func rethrow() {
+	if e := recover(); e != nil {
+		panic(toException(e))
+	}
 }
@@ -13,8 +13,11 @@ func test_eval_ns(ns *Ns, s string) Value {
 			fmt.Printf("%s: %s", e, debug.Stack())
 		}
 	}()
-	sexpr := Read(s)[0]
-	return Eval(ns, sexpr)
+	vals, err := Read(s)
+	if err != nil {
+		panic(err)
+	}
+	return Eval(ns, vals[0])
 }
 
 func test_eval(s string) Value {
@@ -26,6 +29,10 @@ func test_eval(s string) Value {
 
 type xform func(Value) Value
 
+func prnOf(v Value) Value {
+	return Str(v.prn())
+}
+
 type testPair struct {
 	input    string
 	expected Value
@@ -39,7 +46,7 @@ var tests = []testPair{
 	{
 		input: `(let (def-result (def x 100))
 	              (list def-result x))`,
-		expected: sexpr(Nil{}, Int(100)),
+		expected: NewList(Nil{}, Int(100)),
 	},
 
 	{input: "(let (a 1 b 2) a)", expected: Int(1)},
@@ -83,14 +90,458 @@ var tests = []testPair{
 
 	{input: "(do (+ 1 2 3) 5)", expected: Int(5)},
 
+	// collection literals
+
+	{input: "[1 (+ 1 1) [3]]", expected: NewVector(Int(1), Int(2), NewVector(Int(3)))},
+	{input: "{:a (+ 1 1)}", expected: NewHashMap(Keyword("a"), Int(2))},
+	{input: "(let [x 2] #{x})", expected: NewSet(Int(2))},
+	{input: "'[a b]", expected: NewVector(Symbol("a"), Symbol("b"))},
+	{input: "(let [a 1 b (+ a 1)] [a b])", expected: NewVector(Int(1), Int(2))},
+	{input: "((fn [a & more] (list a more)) 1 2 3)", expected: NewList(Int(1), NewList(Int(2), Int(3)))},
+	{input: "(count [1 2 3])", expected: Int(3)},
+	{input: "(count #{1 2})", expected: Int(2)},
+	{input: "(first [4 5])", expected: Int(4)},
+	{input: "(rest [4 5])", expected: Str("(5)"), xform: prnOf},
+	{input: "(nth [4 5] 1)", expected: Int(5)},
+	{input: "(:b {:a 1 :b 2})", expected: Int(2)},
+
+	// syntax quotes
+
+	{input: "`a", expected: Symbol("user/a")},
+	{input: "`(if x (y & z))", expected: sexpr(Symbol("if"), Symbol("user/x"), sexpr(Symbol("user/y"), Symbol("&"), Symbol("user/z")))},
+	{input: "`(a ~(+ 1 2) ~@(list 3 4) ~@nil)", expected: sexpr(Symbol("user/a"), Int(3), Int(3), Int(4))},
+	{input: "`[~@[1 2] {:k ~(+ 1 1)}]", expected: NewVector(Int(1), Int(2), NewHashMap(Keyword("k"), Int(2)))},
+	{input: "`other/x", expected: Symbol("other/x")},
+	{input: "`(a `(b ~c))", expected: sexpr(Symbol("user/a"), sexpr(Symbol("syntax-quote"), sexpr(Symbol("user/b"), sexpr(Symbol("unquote"), Symbol("user/c")))))},
+	{input: "(do (defmacro def-adder [name n] `(defmacro ~name [x#] `(+ ~x# ~~n))) (def-adder add-five 5) (add-five 1))", expected: Int(6)},
+	{input: "(let [c 1] `(a `(b ~~c ~@d)))", expected: sexpr(Symbol("user/a"), sexpr(Symbol("syntax-quote"), sexpr(Symbol("user/b"), sexpr(Symbol("unquote"), Int(1)), sexpr(Symbol("unquote-splicing"), Symbol("user/d")))))},
+	{input: "(let [form `(x# x#)] (count (hash-map (first form) 1 (second form) 2)))", expected: Int(1)},
+	{input: "(user/inc 1)", expected: Int(2)},
+	{
+		input: `(do (defmacro plus-one (x) ` + "`" + `(inc ~x))
+		            (let [inc dec] (plus-one (inc 1))))`,
+		expected: Int(1),
+	},
+	{
+		input:    "(do (defmacro twice (x) `(let [v# ~x] (+ v# v#))) (twice 21))",
+		expected: Int(42),
+	},
+
+	// equality
+
+	{input: "(= 'a 'a)", expected: Boolean(true)},
+	{input: "(= 'a 'b)", expected: Boolean(false)},
+	{input: `(= "x" "x" "x")`, expected: Boolean(true)},
+	{input: `(= "a" 'a :a)`, expected: Boolean(false)},
+	{input: "(= nil nil)", expected: Boolean(true)},
+	{input: "(= nil false)", expected: Boolean(false)},
+	{input: "(= '(1 (2 [3])) (list 1 [2 '(3)]))", expected: Boolean(true)},
+	{input: "(= '(1 2) '(1 2 3))", expected: Boolean(false)},
+	{input: "(= {:a [1] :b 2} (hash-map :b 2 :a [1]))", expected: Boolean(true)},
+	{input: "(= {:a 1} {:a 2})", expected: Boolean(false)},
+	{input: "(= #{1 :x} #{:x 1})", expected: Boolean(true)},
+	{input: "(= {} [])", expected: Boolean(false)},
+	{input: "(= inc inc)", expected: Boolean(true)},
+	{input: "(= inc dec)", expected: Boolean(false)},
+	{input: "(not= 1 2)", expected: Boolean(true)},
+	{input: "(not= '(1) [1])", expected: Boolean(false)},
+	{input: "(= (hash '(1 \"a\")) (hash [1 \"a\"]))", expected: Boolean(true)},
+	{input: "(= (hash 1) (hash 1N))", expected: Boolean(true)},
+
+	// maps with any kind of key
+
+	{input: "(get (hash-map '(1 2) 3) '(1 2))", expected: Int(3)},
+	{input: "(get {[1] :a} '(1))", expected: Keyword("a")},
+	{input: "(get {{:k 1} :a} {:k 1})", expected: Keyword("a")},
+	{input: "(get {} :missing)", expected: Nil{}},
+	{input: "(get {} :missing :default)", expected: Keyword("default")},
+	{input: "(count (put (put {} 1 :int) 1N :big))", expected: Int(1)},
+	{input: "(let [k (list 1)] (count #{k [1] '(1)}))", expected: Int(1)},
+
+	// map functions
+
+	{input: "(assoc {:a 1} :b 2 :a 3)", expected: NewHashMap(Keyword("a"), Int(3), Keyword("b"), Int(2))},
+	{input: "(assoc nil :a 1)", expected: NewHashMap(Keyword("a"), Int(1))},
+	{input: "(dissoc {:a 1 :b 2 :c 3} :a :c :missing)", expected: NewHashMap(Keyword("b"), Int(2))},
+	{input: "(update {:n 1} :n inc)", expected: NewHashMap(Keyword("n"), Int(2))},
+	{input: "(update {:n 1} :n + 10 20)", expected: NewHashMap(Keyword("n"), Int(31))},
+	{input: "(update {} :xs (fn [xs] (cons 1 xs)))", expected: NewHashMap(Keyword("xs"), NewList(Int(1)))},
+	{input: "(merge {:a 1} nil {:b 2 :a 3})", expected: NewHashMap(Keyword("a"), Int(3), Keyword("b"), Int(2))},
+	{input: "(merge)", expected: Nil{}},
+	{input: "(= (keys {:a 1 :b 2}) (keys {:b 2 :a 1}))", expected: Boolean(true)},
+	{input: "(count (vals {:a 1 :b 1}))", expected: Int(2)},
+	{input: "(keys {})", expected: Nil{}},
+	{input: "(contains? {:a nil} :a)", expected: Boolean(true)},
+	{input: "(contains? {:a 1} :b)", expected: Boolean(false)},
+	{input: "(contains? #{[1]} '(1))", expected: Boolean(true)},
+	{input: "(contains? [5 6] 1)", expected: Boolean(true)},
+	{input: "(contains? [5 6] 2)", expected: Boolean(false)},
+	{input: "(:a {:a 'x})", expected: Symbol("x")},
+	{input: "(:b {:a 1} :none)", expected: Keyword("none")},
+	{
+		input: `(let [build (fn [m i] (if (< i 10000) (recur (assoc m i (* i i)) (inc i)) m))
+		             m (build {} 0)]
+		         (list (count m) (get m 9999) (count (dissoc m 0 1 2))))`,
+		expected: NewList(Int(10000), Int(99980001), Int(9997)),
+	},
+
+	// vector functions
+
+	{input: "(vector 1 2)", expected: NewVector(Int(1), Int(2))},
+	{input: "(vector)", expected: NewVector()},
+	{input: "(conj [1] 2 3)", expected: NewVector(Int(1), Int(2), Int(3))},
+	{input: "(conj '(1) 2 3)", expected: NewList(Int(3), Int(2), Int(1))},
+	{input: "(conj nil 1)", expected: NewList(Int(1))},
+	{input: "(nth [1 2 3] 1)", expected: Int(2)},
+	{input: "(nth [1 2 3] 3 :none)", expected: Keyword("none")},
+	{input: "(nth [1 2 3] -1 :none)", expected: Keyword("none")},
+	{input: "(nth '(1 2) 5 :none)", expected: Keyword("none")},
+	{input: "(nth nil 0 :none)", expected: Keyword("none")},
+	{input: "(get [:a :b] 1)", expected: Keyword("b")},
+	{input: "(get [:a :b] 2 :none)", expected: Keyword("none")},
+	{input: "(assoc [1 2 3] 0 :a 3 :d)", expected: NewVector(Keyword("a"), Int(2), Int(3), Keyword("d"))},
+	{input: "(subvec [1 2 3 4] 1 3)", expected: NewVector(Int(2), Int(3))},
+	{input: "(subvec [1 2 3 4] 4)", expected: NewVector()},
+	{input: "(peek [1 2 3])", expected: Int(3)},
+	{input: "(peek [])", expected: Nil{}},
+	{input: "(peek '(1 2 3))", expected: Int(1)},
+	{input: "(pop [1 2 3])", expected: NewVector(Int(1), Int(2))},
+	{input: "(pop (list 1 2 3))", expected: NewList(Int(2), Int(3))},
+	{input: "(first [7 8])", expected: Int(7)},
+	{input: "(last [7 8])", expected: Int(8)},
+	{input: "(count [7 8])", expected: Int(2)},
+	{input: "(seq [7 8])", expected: Str("(7 8)"), xform: prnOf},
+	{input: "(= [1 2] (conj [1] 2) '(1 2))", expected: Boolean(true)},
+	{input: `(let [build (fn [v i] (if (< i 10000) (recur (conj v i) (inc i)) v))
+	               v (build [] 0)]
+	           (list (count v) (nth v 9999) (peek (pop v)) (nth (assoc v 5000 :x) 5000)))`,
+		expected: NewList(Int(10000), Int(9999), Int(9998), Keyword("x"))},
+
+	// set functions
+
+	{input: "(hash-set 1 2 1)", expected: NewSet(Int(1), Int(2))},
+	{input: "(conj #{1} 2 1)", expected: NewSet(Int(1), Int(2))},
+	{input: "(disj #{1 2 3} 1 3 4)", expected: NewSet(Int(2))},
+	{input: "(disj nil 1)", expected: Nil{}},
+	{input: "(union #{1 2} #{2 3} nil)", expected: NewSet(Int(1), Int(2), Int(3))},
+	{input: "(union)", expected: NewSet()},
+	{input: "(intersection #{1 2 3} #{2 3 4} #{3 2})", expected: NewSet(Int(2), Int(3))},
+	{input: "(intersection #{1 2} nil)", expected: NewSet()},
+	{input: "(difference #{1 2 3} #{2} #{3 4})", expected: NewSet(Int(1))},
+	{input: "(subset? #{1 2} #{1 2 3})", expected: Boolean(true)},
+	{input: "(subset? #{1 4} #{1 2 3})", expected: Boolean(false)},
+	{input: "(subset? nil #{})", expected: Boolean(true)},
+	{input: "(select (fn [x] (> x 1)) #{1 2 3})", expected: NewSet(Int(2), Int(3))},
+	{input: "(select :a #{{:a 1} {:b 2}})", expected: NewSet(NewHashMap(Keyword("a"), Int(1)))},
+	{input: "(#{:a :b} :a)", expected: Keyword("a")},
+	{input: "(#{:a :b} :c)", expected: Nil{}},
+	{input: "(let [x 1] (#{x} 1))", expected: Int(1)},
+	{input: "(#{(inc 0)} 1)", expected: Int(1)},
+	{input: "(let [vowel? #{\"a\" \"e\"}] (list (vowel? \"a\") (vowel? \"b\")))", expected: NewList(Str("a"), Nil{})},
+	{input: "(select #{1 3} #{1 2 3})", expected: NewSet(Int(1), Int(3))},
+
+	// sorted maps and sets
+
+	{input: "(sorted-map :c 3 :a 1 :b 2)", expected: Str("{:a 1 :b 2 :c 3}"), xform: prnOf},
+	{input: "(sorted-map-by > 1 :a 3 :c 2 :b)", expected: Str("{3 :c 2 :b 1 :a}"), xform: prnOf},
+	{input: "(sorted-map-by (fn [a b] (compare b a)) \"x\" 1 \"y\" 2)", expected: Str(`{"y" 2 "x" 1}`), xform: prnOf},
+	{input: "(sorted-set 3 1 2 1)", expected: Str("#{1 2 3}"), xform: prnOf},
+	{input: "(sorted-set-by > 3 1 2)", expected: Str("#{3 2 1}"), xform: prnOf},
+	{input: "(assoc (sorted-map 2 :b) 1 :a 3 :c)", expected: Str("{1 :a 2 :b 3 :c}"), xform: prnOf},
+	{input: "(dissoc (sorted-map 1 :a 2 :b) 1)", expected: Str("{2 :b}"), xform: prnOf},
+	{input: "(merge (sorted-map :b 2) {:a 1})", expected: Str("{:a 1 :b 2}"), xform: prnOf},
+	{input: "(conj (sorted-set 2) 1 3)", expected: Str("#{1 2 3}"), xform: prnOf},
+	{input: "(disj (sorted-set 1 2 3) 2)", expected: Str("#{1 3}"), xform: prnOf},
+	{input: "(keys (sorted-map :b 2 :a 1))", expected: NewList(Keyword("a"), Keyword("b"))},
+	{input: "(vals (sorted-map :b 2 :a 1))", expected: NewList(Int(1), Int(2))},
+	{input: "(seq (sorted-map :b 2 :a 1))", expected: NewList(sexpr(Keyword("a"), Int(1)), sexpr(Keyword("b"), Int(2)))},
+	{input: "(get (sorted-map :a 1) :a)", expected: Int(1)},
+	{input: "(:b (sorted-map :a 1) :none)", expected: Keyword("none")},
+	{input: "(contains? (sorted-set 1 2) 2)", expected: Boolean(true)},
+	{input: "((sorted-set 1 2) 2)", expected: Int(2)},
+	{input: "(count (sorted-map 1 1 2 2))", expected: Int(2)},
+	{input: "(= (sorted-map :a 1 :b 2) {:b 2 :a 1})", expected: Boolean(true)},
+	{input: "(= (sorted-set 1 2) #{2 1})", expected: Boolean(true)},
+	{input: "(= (hash (sorted-set 1 2)) (hash #{2 1}))", expected: Boolean(true)},
+	{input: "(compare 1 2)", expected: Int(-1)},
+	{input: "(compare \"b\" \"a\")", expected: Int(1)},
+	{input: "(compare [1 2] [1 2])", expected: Int(0)},
+	{input: "(compare [9] [1 2])", expected: Int(-1)},
+	{input: "(compare nil false)", expected: Int(-1)},
+	{input: "(compare 1/2 0.25)", expected: Int(1)},
+	{input: "(subseq (sorted-set 1 2 3 4 5) > 2)", expected: NewList(Int(3), Int(4), Int(5))},
+	{input: "(subseq (sorted-set 1 2 3 4 5) >= 2 < 4)", expected: NewList(Int(2), Int(3))},
+	{input: "(rsubseq (sorted-set 1 2 3 4 5) <= 3)", expected: NewList(Int(3), Int(2), Int(1))},
+	{input: "(subseq (sorted-set 1 2 3) > 3)", expected: Nil{}},
+	{input: "(subseq (sorted-map 1 :a 2 :b 3 :c) >= 2)", expected: NewList(sexpr(Int(2), Keyword("b")), sexpr(Int(3), Keyword("c")))},
+
+	// sequences
+
+	{input: "(first \"héllo\")", expected: Str("h")},
+	{input: "(rest \"abc\")", expected: NewList(Str("b"), Str("c"))},
+	{input: "(nth \"héllo\" 1)", expected: Str("é")},
+	{input: "(count \"héllo\")", expected: Int(5)},
+	{input: "(seq \"\")", expected: Nil{}},
+	{input: "(seq [])", expected: Nil{}},
+	{input: "(seq nil)", expected: Nil{}},
+	{input: "(count nil)", expected: Int(0)},
+	{input: "(first {:a 1})", expected: sexpr(Keyword("a"), Int(1))},
+	{input: "(first (sorted-set 3 1 2))", expected: Int(1)},
+	{input: "(rest (sorted-set 3 1 2))", expected: NewList(Int(2), Int(3))},
+	{input: "(last (sorted-map :b 2 :a 1))", expected: sexpr(Keyword("b"), Int(2))},
+	{input: "(nth (rest [1 2 3]) 1)", expected: Int(3)},
+	{input: "(count (cons 0 [1 2]))", expected: Int(3)},
+	{input: "(= (cons 0 [1 2]) '(0 1 2) [0 1 2])", expected: Boolean(true)},
+	{input: "(= (hash (cons 0 [1 2])) (hash '(0 1 2)))", expected: Boolean(true)},
+	{input: "(first (rest (cons 0 (sorted-set 2 1))))", expected: Int(1)},
+	{input: "(map inc [1 2 3])", expected: Str("(2 3 4)"), xform: prnOf},
+	{input: "(reverse \"abc\")", expected: NewList(Str("c"), Str("b"), Str("a"))},
+	{input: "(do (defmacro unless (test x) (cons 'if (cons test (cons nil [x])))) (unless false 1))", expected: Int(1)},
+
+	// lazy sequences
+
+	{input: "(take 5 (iterate inc 0))", expected: Str("(0 1 2 3 4)"), xform: prnOf},
+	{input: "(take 3 (range))", expected: Str("(0 1 2)"), xform: prnOf},
+	{input: "(range 3)", expected: Str("(0 1 2)"), xform: prnOf},
+	{input: "(range 1 10 4)", expected: Str("(1 5 9)"), xform: prnOf},
+	{input: "(range 3 0 -1)", expected: Str("(3 2 1)"), xform: prnOf},
+	{input: "(range 0 1 1/3)", expected: Str("(0 1/3 2/3)"), xform: prnOf},
+	{input: "(range 0)", expected: Str("()"), xform: prnOf},
+	{input: "(seq (range 0))", expected: Nil{}},
+	{input: "(repeat 2 :x)", expected: Str("(:x :x)"), xform: prnOf},
+	{input: "(take 3 (repeat :x))", expected: Str("(:x :x :x)"), xform: prnOf},
+	{input: "(take 5 (cycle [1 2]))", expected: Str("(1 2 1 2 1)"), xform: prnOf},
+	{input: "(cycle [])", expected: Str("()"), xform: prnOf},
+	{input: "(take 2 (drop 3 (range)))", expected: Str("(3 4)"), xform: prnOf},
+	{input: "(drop 5 [1 2])", expected: Str("()"), xform: prnOf},
+	{input: "(take-while (fn [x] (< x 3)) (range))", expected: Str("(0 1 2)"), xform: prnOf},
+	{input: "(concat [1 2] nil '(3) (range 4 6))", expected: Str("(1 2 3 4 5)"), xform: prnOf},
+	{input: "(take 4 (concat [1] (range)))", expected: Str("(1 0 1 2)"), xform: prnOf},
+	{input: "(= (range 3) [0 1 2])", expected: Boolean(true)},
+	{input: "(nth (iterate (fn [x] (* x 2)) 1) 64)", expected: Str("18446744073709551616N"), xform: prnOf},
+	{input: "(first (lazy-seq '(1 2)))", expected: Int(1)},
+	{input: "(lazy-seq nil)", expected: Str("()"), xform: prnOf},
+	{input: "(do (defn nat-from [n] (lazy-seq (cons n (nat-from (inc n))))) (take 3 (nat-from 5)))", expected: Str("(5 6 7)"), xform: prnOf},
+	{input: "(let [x 1 s (lazy-seq (list x))] (let [x 2] s))", expected: Str("(1)"), xform: prnOf},
+	{input: "(= (range) [0 1])", expected: Boolean(false)},
+	{input: "(= [0 1] (range))", expected: Boolean(false)},
+	{input: "(= (range) (range 1))", expected: Boolean(false)},
+	{input: "(let [s (lazy-seq (throw (ex-info \"boom\" {})))] (try (first s) (catch e nil)) (try (seq s) (catch e (ex-message e))))", expected: Str("boom")},
+	{
+		input: `(let [sum (fn [s acc] (if (empty? s) acc (recur (rest s) (+ acc (first s)))))]
+		         (sum (take 10000 (range)) 0))`,
+		expected: Int(49995000),
+	},
+
+	// sequence library
+
+	{input: "(map + [1 2 3] '(10 20))", expected: Str("(11 22)"), xform: prnOf},
+	{input: "(map list [1 2] [:a :b] \"xy\")", expected: Str(`((1 :a "x") (2 :b "y"))`), xform: prnOf},
+	{input: "(take 3 (map inc (range)))", expected: Str("(1 2 3)"), xform: prnOf},
+	{input: "(map inc nil)", expected: Str("()"), xform: prnOf},
+	{input: "(filter (fn [x] (> x 1)) [1 2 3 0 5])", expected: Str("(2 3 5)"), xform: prnOf},
+	{input: "(take 2 (filter (fn [x] (= 0 (- x (* 2 (/ x 2))))) (range 1 100)))", expected: Str("(1 2)"), xform: prnOf},
+	{input: "(reduce + [1 2 3])", expected: Int(6)},
+	{input: "(reduce + 10 [1 2 3])", expected: Int(16)},
+	{input: "(reduce + [])", expected: Int(0)},
+	{input: "(reduce + [5])", expected: Int(5)},
+	{input: "(reduce (fn [m x] (assoc m x true)) {} '(:a :b))", expected: NewHashMap(Keyword("a"), Boolean(true), Keyword("b"), Boolean(true))},
+	{input: "(reduce + (take 100 (range)))", expected: Int(4950)},
+	{input: "(reverse [1 2 3])", expected: NewList(Int(3), Int(2), Int(1))},
+	{input: "(reverse nil)", expected: List{}},
+	{input: "(sort [3 1 2])", expected: NewList(Int(1), Int(2), Int(3))},
+	{input: "(sort > [3 1 2])", expected: NewList(Int(3), Int(2), Int(1))},
+	{input: "(sort (fn [a b] (compare (count a) (count b))) [\"ccc\" \"a\" \"bb\" \"b\"])", expected: NewList(Str("a"), Str("b"), Str("bb"), Str("ccc"))},
+	{input: "(sort #{:b :c :a})", expected: NewList(Keyword("a"), Keyword("b"), Keyword("c"))},
+	{input: "(group-by count [\"a\" \"bb\" \"c\"])", expected: NewHashMap(Int(1), NewVector(Str("a"), Str("c")), Int(2), NewVector(Str("bb")))},
+	{input: "(partition 2 [1 2 3 4 5])", expected: Str("((1 2) (3 4))"), xform: prnOf},
+	{input: "(partition 3 1 [1 2 3 4])", expected: Str("((1 2 3) (2 3 4))"), xform: prnOf},
+	{input: "(take 2 (partition 2 (range)))", expected: Str("((0 1) (2 3))"), xform: prnOf},
+	{input: "(frequencies [:a :b :a])", expected: NewHashMap(Keyword("a"), Int(2), Keyword("b"), Int(1))},
+	{input: "(distinct [1 2 1 3 2])", expected: Str("(1 2 3)"), xform: prnOf},
+	{input: "(take 3 (distinct (cycle [1 2 3])))", expected: Str("(1 2 3)"), xform: prnOf},
+	{input: "(interleave [1 2 3] [:a :b])", expected: Str("(1 :a 2 :b)"), xform: prnOf},
+	{input: "(interleave)", expected: Str("()"), xform: prnOf},
+	{input: "(some (fn [x] (> x 2)) [1 2 3])", expected: Boolean(true)},
+	{input: "(some #{3 4} [1 2 3])", expected: Int(3)},
+	{input: "(some :a [{:b 1}])", expected: Nil{}},
+	{input: "(every? (fn [x] (> x 0)) [1 2 3])", expected: Boolean(true)},
+	{input: "(every? (fn [x] (> x 1)) [1 2 3])", expected: Boolean(false)},
+	{input: "(every? :a [])", expected: Boolean(true)},
+
+	// loop and recur
+
+	{input: "(loop [i 0 acc 0] (if (< i 5) (recur (inc i) (+ acc i)) acc))", expected: Int(10)},
+	{input: "(loop (a 1 b (inc a)) b)", expected: Int(2)},
+	{input: "(fib 10)", expected: Str("(0 1 1 2 3 5 8 13 21 34)"), xform: prnOf},
+	{input: "(loop [i 0] (let [j (inc i)] (if (< j 3) (recur j) j)))", expected: Int(3)},
+	{input: "(loop [i 0] (when (< i 3) (recur (inc i))))", expected: Nil{}},
+	{input: "(loop [i 0] (do :x (if (< i 100000) (recur (inc i)) i)))", expected: Int(100000)},
+	{input: "(loop [i 0 n 0] (if (< i 3) (recur (inc i) (+ n (loop [j 0] (if (< j 2) (recur (inc j)) j)))) n))", expected: Int(6)},
+	{input: "((fn [a b] (loop [i a] (if (< i b) (recur (inc i)) i))) 1 4)", expected: Int(4)},
+	{input: "((fn [x & more] (if more (recur (+ x (first more))) x)) 1 2)", expected: Int(3)},
+	{input: "(try (fn [x] (inc (recur x))) (catch e (ex-message e)))", expected: Str("recur must be in tail position: (recur x)")},
+	{input: "(try (fn [x] (let [y (recur 1)] y)) (catch e (ex-message e)))", expected: Str("recur must be in tail position: (recur 1)")},
+	{input: "(try (fn [x] (if false (recur 1 2) x)) (catch e (ex-message e)))", expected: Str("recur passes 2 values to a fn that takes 1: (recur 1 2)")},
+	{input: "(try (loop [i 0] (recur)) (catch e (ex-message e)))", expected: Str("recur passes 0 values to a loop that takes 1: (recur)")},
+	{input: "(try (loop [i 0] (try (recur 1))) (catch e (ex-message e)))", expected: Str("recur must be in tail position: (recur 1)")},
+	{input: "(try (fn [] (lazy-seq (recur))) (catch e (ex-message e)))", expected: Str("recur must be in tail position: (recur)")},
+	{input: "(try ((fn [x] (when x (+ 1 (recur x)))) true) (catch e (ex-message e)))", expected: Str("recur must be in tail position: (recur x)")},
+	{input: "(try ((fn [x] (when x (recur x 1))) true) (catch e (ex-message e)))", expected: Str("recur passes 2 values to a fn that takes 1: (recur x 1)")},
+	{input: "(try (loop [i 0] (let [j (when true (recur 1))] j)) (catch e (ex-message e)))", expected: Str("recur must be in tail position: (recur 1)")},
+	{input: "(str (loop [i 0] (when (< i 3) (recur (inc i)))))", expected: Str("nil")},
+	{input: "(map (fn [f] (f)) (loop [i 0 acc []] (if (< i 3) (recur (inc i) (conj acc (fn [] i))) acc)))", expected: Str("(0 1 2)"), xform: prnOf},
+	{input: "(map (fn [f] (f)) ((fn [i acc] (if (< i 3) (recur (inc i) (conj acc (fn [] i))) acc)) 0 []))", expected: Str("(0 1 2)"), xform: prnOf},
+	{input: "(try (loop [i 0] (if (< i 2) (recur i 1) i)) (catch e (ex-message e)))", expected: Str("recur passes 2 values to a loop that takes 1: (recur i 1)")},
+	{input: "(try (loop [x]) (catch e (ex-message e)))", expected: Str("loop's binding list must be an even number of values: [x]")},
+
+	// exceptions
+
+	{input: "(try 1 2)", expected: Int(2)},
+	{input: "(try (+ 1 2) (catch e :caught))", expected: Int(3)},
+	{input: "(try (throw (ex-info \"boom\" {:a 1})) (catch e (ex-message e)))", expected: Str("boom")},
+	{input: "(try (throw (ex-info \"boom\" {:a 1})) (catch e (ex-data e)))", expected: NewHashMap(Keyword("a"), Int(1))},
+	{input: "(try (get) (catch e (ex-data e)))", expected: Nil{}},
+	{input: "(try (get) (catch e (ex-message e)))", expected: Str("get takes 2 or 3 parameters: []")},
+	{input: "(try ((fn [x] x)) (catch e :arity))", expected: Keyword("arity")},
+	{input: "(try (undefined-thing) (catch e :unbound))", expected: Keyword("unbound")},
+	{input: "(let [x 1] (try (let [x 2] (throw (ex-info \"\" {}))) (catch e x)))", expected: Int(1)},
+	{input: "(try (throw (ex-info \"outer\" {} (ex-info \"inner\" {}))) (catch e (ex-message (ex-cause e))))", expected: Str("inner")},
+	{input: "(try (try (throw (ex-info \"a\" {})) (catch e (throw (ex-info \"b\" {} e)))) (catch e (str (ex-message e) (ex-message (ex-cause e)))))", expected: Str("ba")},
+	{input: "(try (throw (ex-info \"x\" {})) (catch e 1) (finally 2))", expected: Int(1)},
+	{input: "(try (try (throw (ex-info \"x\" {})) (finally :ignored)) (catch e (ex-message e)))", expected: Str("x")},
+	{input: "(try (throw 1) (catch e (ex-message e)))", expected: Str("throw takes an exception made by ex-info: 1")},
+	{input: "(ex-message 1)", expected: Nil{}},
+	{input: "(ex-data (ex-info \"x\" nil))", expected: HashMap{}},
+	{input: "(ex-info \"x\" {:a 1})", expected: Str(`#error {:message "x" :data {:a 1}}`), xform: prnOf},
+	{input: "(do (defmacro safe [x] `(try ~x (catch e :caught))) (safe (throw (ex-info \"x\" {}))))", expected: Keyword("caught")},
+	{input: "(do (defmacro safe-finally [x] `(try ~x (finally :ignored))) (safe-finally 1))", expected: Int(1)},
+
+	// higher-order functions
+
+	{input: "(apply + '(1 2 3))", expected: Int(6)},
+	{input: "(apply + 1 2 [3 4])", expected: Int(10)},
+	{input: "(apply + nil)", expected: Int(0)},
+	{input: "(apply str (range 3))", expected: Str("012")},
+	{input: "(apply (fn [a & more] (count more)) 1 2 [3])", expected: Int(2)},
+	{input: "(apply :a [{:a 1}])", expected: Int(1)},
+	{input: "(apply #{1 2} [2])", expected: Int(2)},
+	{input: "(map apply [+ str] [[1 2] [3 4]])", expected: Str(`(3 "34")`), xform: prnOf},
+	{input: "(and true true false)", expected: Boolean(false)},
+	{input: "(and true 1 :a)", expected: Boolean(true)},
+	{input: "((partial + 1 2) 3 4)", expected: Int(10)},
+	{input: "((partial str) \"a\")", expected: Str("a")},
+	{input: "(map (partial * 2) [1 2])", expected: Str("(2 4)"), xform: prnOf},
+	{input: "((comp - +) 1 2)", expected: Int(-3)},
+	{input: "((comp :a first) [{:a 5}])", expected: Int(5)},
+	{input: "((juxt first count) [7 8 9])", expected: NewVector(Int(7), Int(3))},
+	{input: "((juxt +) 1 2)", expected: NewVector(Int(3))},
+	{input: "((complement empty?) [])", expected: Boolean(false)},
+	{input: "(filter (complement #{2}) [1 2 3])", expected: Str("(1 3)"), xform: prnOf},
+	{input: "(identity 4)", expected: Int(4)},
+	{input: "(map identity [nil 1])", expected: Str("(nil 1)"), xform: prnOf},
+	{input: "((constantly 7) 1 2 3)", expected: Int(7)},
+	{input: "((constantly nil))", expected: Nil{}},
+
+	// transducers
+
+	{input: "((comp inc inc) 1)", expected: Int(3)},
+	{input: "((comp str +) 1 2)", expected: Str("3")},
+	{input: "((comp) 5)", expected: Int(5)},
+	{input: "(transduce (map inc) + [1 2 3])", expected: Int(9)},
+	{input: "(transduce (filter (fn [x] (> x 1))) + 10 [1 2 3])", expected: Int(15)},
+	{input: "(transduce (comp (map inc) (filter (fn [x] (> x 2))) (take 2)) conj [] (range))", expected: NewVector(Int(3), Int(4))},
+	{input: "(transduce (take 0) + [1 2 3])", expected: Int(0)},
+	{input: "(transduce (map inc) (fn [a b] (+ a b)) 0 [1 2])", expected: Int(5)},
+	{input: "(transduce (map inc) conj [1 2])", expected: NewVector(Int(2), Int(3))},
+	{input: "(conj)", expected: NewVector()},
+	{input: "(into [] (map inc) '(1 2 3))", expected: NewVector(Int(2), Int(3), Int(4))},
+	{input: "(into [0] [1 2])", expected: NewVector(Int(0), Int(1), Int(2))},
+	{input: "(into '() [1 2])", expected: NewList(Int(2), Int(1))},
+	{input: "(into #{} (map count) [\"a\" \"bb\" \"c\"])", expected: NewSet(Int(1), Int(2))},
+	{input: "(into {} (map (fn [x] [x (* x x)])) [2 3])", expected: NewHashMap(Int(2), Int(4), Int(3), Int(9))},
+	{input: "(into [] (partition-all 2) [1 2 3 4 5])", expected: NewVector(NewVector(Int(1), Int(2)), NewVector(Int(3), Int(4)), NewVector(Int(5)))},
+	{input: "(into [] (comp (partition-all 2) (take 1)) (range))", expected: NewVector(NewVector(Int(0), Int(1)))},
+	{input: "(partition-all 2 [1 2 3])", expected: Str("((1 2) (3))"), xform: prnOf},
+	{input: "(partition-all 2 1 [1 2 3])", expected: Str("((1 2) (2 3) (3))"), xform: prnOf},
+	{input: "(sequence (map inc) [1 2 3])", expected: Str("(2 3 4)"), xform: prnOf},
+	{input: "(sequence (comp (filter (fn [x] (> x 2))) (map str)) [1 2 3 4])", expected: Str(`("3" "4")`), xform: prnOf},
+	{input: "(take 3 (sequence (map inc) (range)))", expected: Str("(1 2 3)"), xform: prnOf},
+	{input: "(sequence (take 2) (range))", expected: Str("(0 1)"), xform: prnOf},
+	{input: "(sequence (partition-all 2) [1 2 3])", expected: Str("([1 2] [3])"), xform: prnOf},
+	{input: "(sequence [1 2])", expected: Str("(1 2)"), xform: prnOf},
+	{input: "(sequence nil)", expected: List{}},
+	{input: "(eduction (map inc) (filter (fn [x] (> x 2))) [1 2 3])", expected: Str("(3 4)"), xform: prnOf},
+	{input: "(reduce + (eduction (map inc) [1 2 3]))", expected: Int(9)},
+	{input: "(reduce (fn [acc x] (+ acc x)) 100 (eduction (map inc) [1 2 3]))", expected: Int(109)},
+	{input: "(reduce (fn [a b] (+ a b)) (eduction (map inc) [1 2]))", expected: Int(5)},
+	{input: "(reduce + (eduction (filter (fn [x] (> x 5))) [2 4]))", expected: Int(0)},
+	{input: "(let [e (eduction (map inc) [1 2])] (= e (into [] e) '(2 3)))", expected: Boolean(true)},
+	{input: "(count (eduction (filter (fn [x] (> x 1))) [1 2 3]))", expected: Int(2)},
+	{input: "(reduce (fn [acc x] (if (> x 2) (reduced acc) (+ acc x))) (range))", expected: Int(3)},
+	{input: "(reduced? (reduced 1))", expected: Boolean(true)},
+	{input: "(reduced? 1)", expected: Boolean(false)},
+
 	// builtin functions (not macros)
 
-	{input: "(list 1 2 3)", expected: sexpr(Int(1), Int(2), Int(3))},
+	{input: "(list 1 2 3)", expected: NewList(Int(1), Int(2), Int(3))},
 	{input: "(first '(1 2 3))", expected: Int(1)},
 	{input: "(rest '(1 2 3))", expected: sexpr(Int(2), Int(3))},
-	{input: "(cons 100 '())", expected: sexpr(Int(100))},
+	{input: "(cons 100 '())", expected: NewList(Int(100))},
+	{input: "(cons 1 (cons 2 nil))", expected: NewList(Int(1), Int(2))},
+	{input: "(cons 1 [2 3])", expected: Str("(1 2 3)"), xform: prnOf},
+	{input: "(rest (list 1 2 3))", expected: NewList(Int(2), Int(3))},
+	{input: "(rest (list 1))", expected: List{}},
+	{input: "(rest (list))", expected: List{}},
+	{input: "(rest nil)", expected: List{}},
+	{input: "(first (list))", expected: Nil{}},
+	{input: "(count (cons 1 (list 2 3)))", expected: Int(3)},
+	{input: "(nth (list 1 2 3) 2)", expected: Int(3)},
+	{input: "(last (list 1 2 3))", expected: Int(3)},
+	{input: "(= (list 1 2) '(1 2) [1 2])", expected: Boolean(true)},
+	{input: "(seq (list 1 2))", expected: NewList(Int(1), Int(2))},
+	{input: "(do (defmacro with-one (name & body) (cons 'let (cons (list name 1) body))) (with-one x (+ x 2)))", expected: Int(3)},
+	{
+		input: `(let [build (fn [l i] (if (< i 20000) (recur (cons i l) (inc i)) l))
+		             l (build nil 0)]
+		         (list (count l) (first l) (first (reverse l)) (second (map inc l))))`,
+		expected: NewList(Int(20000), Int(19999), Int(0), Int(19999)),
+	},
 	{input: "(+ 1 2 3)", expected: Int(6)},
 
+	// numbers
+
+	{input: "(+)", expected: Int(0)},
+	{input: "(*)", expected: Int(1)},
+	{input: "(* 2 3 4)", expected: Int(24)},
+	{input: "(- 5)", expected: Int(-5)},
+	{input: "(/ 6 3)", expected: Int(2)},
+	{input: "(/ 1 3)", expected: Str("1/3"), xform: prnOf},
+	{input: "(/ 4)", expected: Str("1/4"), xform: prnOf},
+	{input: "(+ 1/3 2/3)", expected: Int(1)},
+	{input: "(* 1/2 4)", expected: Int(2)},
+	{input: "(+ 1 1.5)", expected: Float(2.5)},
+	{input: "(/ 1 2.0)", expected: Float(0.5)},
+	{input: "(+ 1/2 0.25)", expected: Float(0.75)},
+	{input: "(/ 1.0 0)", expected: Str("##Inf"), xform: prnOf},
+	{input: "(+ 9223372036854775807 1)", expected: Str("9223372036854775808N"), xform: prnOf},
+	{input: "(- -9223372036854775808 1)", expected: Str("-9223372036854775809N"), xform: prnOf},
+	{input: "(* 9223372036854775807 2)", expected: Str("18446744073709551614N"), xform: prnOf},
+	{input: "(- -9223372036854775808)", expected: Str("9223372036854775808N"), xform: prnOf},
+	{input: "(+ 1N 1)", expected: Str("2N"), xform: prnOf},
+	{input: "(/ 10N 4)", expected: Str("5/2"), xform: prnOf},
+	{input: "(/ 10N 5)", expected: Int(2)},
+	{input: "(< 1 3/2 1.75 2N)", expected: Boolean(true)},
+	{input: "(< 1 1)", expected: Boolean(false)},
+	{input: "(> 3 1 2)", expected: Boolean(false)},
+	{input: "(>= 3 3 2.5)", expected: Boolean(true)},
+	{input: "(<= 1 ##NaN)", expected: Boolean(false)},
+	{input: "(= 1 1N)", expected: Boolean(true)},
+	{input: "(= 1 1.0)", expected: Boolean(false)},
+	{input: "(== 1 1.0 1N)", expected: Boolean(true)},
+	{input: "(= 1/2 2/4)", expected: Boolean(true)},
+	{input: `(str "a\"" 1 '("b"))`, expected: Str(`a"1("b")`)},
+
 	// keywords as basic functions
 
 	{input: "(:a (hash-map :a :B))", expected: Keyword("B")},
@@ -98,7 +549,8 @@ var tests = []testPair{
 	// keywords as higher-order functions
 	{
 		input:    "(map :a (list (hash-map :a \"ONE\") (hash-map :a \"TWO\")))",
-		expected: sexpr(Str("ONE"), Str("TWO")),
+		expected: Str(`("ONE" "TWO")`),
+		xform:    prnOf,
 	},
 }
 
@@ -119,3 +571,78 @@ func TestEval(t *testing.T) {
 		}
 	}
 }
+
+func TestEqualHash(t *testing.T) {
+	pairs := []string{
+		"a", "a",
+		"1", "1N",
+		"(1 [2])", "[1 (2)]",
+		"{:a 1 :b 2}", "{:b 2 :a 1}",
+		"#{1 2 3}", "#{3 2 1}",
+		"0.0", "-0.0",
+	}
+	for i := 0; i < len(pairs); i += 2 {
+		a, b := readOne(pairs[i]), readOne(pairs[i+1])
+		if !Equal(a, b) || Hash(a) != Hash(b) {
+			t.Errorf("%v and %v should be equal and hash alike", a, b)
+		}
+	}
+
+	different := []string{"a", "\"a\"", ":a", "1", "1.0", "nil", "false", "()", "{}", "#{}", "(nil)"}
+	for i, s := range different {
+		for _, other := range different[i+1:] {
+			a, b := readOne(s), readOne(other)
+			if Equal(a, b) || Hash(a) == Hash(b) {
+				t.Errorf("%v and %v should not be equal or hash alike", a, b)
+			}
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	// deep enough to overflow this stack if tail calls grew it
+	defer debug.SetMaxStack(debug.SetMaxStack(16 << 20))
+
+	ns := DefaultNs()
+	test_eval_ns(ns, "(defn tail-even? (n) (if (= n 0) true (tail-odd? (- n 1))))")
+	test_eval_ns(ns, "(defn tail-odd? (n) (if (= n 0) false (tail-even? (- n 1))))")
+	assertEqual(t, test_eval_ns(ns, "(tail-even? 100000)"), Boolean(true))
+	assertEqual(t, test_eval_ns(ns, "(tail-odd? 100001)"), Boolean(true))
+
+	// through let, do and the macros that expand to them
+	test_eval_ns(ns, "(defn tail-count (n acc) (let [m (- n 1)] (do :ignored (if (< m 0) acc (tail-count m (+ acc 1))))))")
+	assertEqual(t, test_eval_ns(ns, "(tail-count 100000 0)"), Int(100000))
+	test_eval_ns(ns, "(defn tail-down (n) (when (> n 0) (tail-down (- n 1))))")
+	assertEqual(t, test_eval_ns(ns, "(tail-down 100000)"), Nil{})
+
+	// a fn in the head of the form, and calls that aren't in tail position
+	assertEqual(t, test_eval_ns(ns, "((fn [n] (if (= n 0) :done ((fn [m] (tail-even? m)) n))) 100000)"), Boolean(true))
+	assertEqual(t, test_eval_ns(ns, "(inc (tail-count 10 0))"), Int(11))
+	assertEqual(t, len(stack), 0)
+}
+
+func TestRecurOutsideFn(t *testing.T) {
+	defer func() {
+		assertEqual(t, recover().(ExInfo).message, "recur used outside of a fn or loop: (recur 1)")
+	}()
+	Eval(DefaultNs(), readOne("(recur 1)"))
+	t.Fail()
+}
+
+func TestRecurInMacros(t *testing.T) {
+	ns := DefaultNs()
+
+	// a macro whose body calls a fn, used in a fn before any call is made
+	test_eval_ns(ns, "(defn recur-helper [x] x)")
+	test_eval_ns(ns, "(defmacro recur-m [x] (recur-helper x))")
+	assertEqual(t, test_eval_ns(ns, "((fn [] (recur-m 1)))"), Int(1))
+	assertEqual(t, len(stack), 0)
+
+	// macros are expanded once each time they are evaluated, and no more
+	test_eval_ns(ns, "(defmacro counted [x] (do (gensym) x))")
+	before := gensymCounter
+	test_eval_ns(ns, "(defn use-counted [] (counted 1))")
+	assertEqual(t, gensymCounter, before)
+	test_eval_ns(ns, "(use-counted)")
+	assertEqual(t, gensymCounter, before+1)
+}
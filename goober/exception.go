@@ -0,0 +1,51 @@
+package goober
+
+import "fmt"
+import "strings"
+
+// An exception, as made by ex-info and thrown with throw. Errors goober
+// itself panics with, like calling a function with the wrong number of
+// arguments, are turned into exceptions too as they leave the call they
+// happened in, or Eval if they happened outside of any call, with just a
+// message.
+type ExInfo struct {
+	message string
+	data    Value   // a map, or nil
+	cause   Value   // the exception this one was thrown because of, or nil
+	trace   []frame // the calls it was thrown from, set once it is thrown
+}
+
+func (v ExInfo) Error() string {
+	return v.message
+}
+
+func (v ExInfo) truthy() bool {
+	return true
+}
+
+func (v ExInfo) prn() string {
+	fields := []string{":message " + Str(v.message).prn()}
+	if _, ok := v.data.(Nil); !ok {
+		fields = append(fields, ":data "+v.data.prn())
+	}
+	if _, ok := v.cause.(Nil); !ok {
+		fields = append(fields, ":cause "+v.cause.prn())
+	}
+	return "#error {" + strings.Join(fields, " ") + "}"
+}
+
+func (v ExInfo) String() string {
+	return v.prn()
+}
+
+// Returns the exception for a value recovered from a panic.
+func toException(e interface{}) ExInfo {
+	switch e := e.(type) {
+	case ExInfo:
+		return e
+	case error:
+		return ExInfo{message: e.Error(), data: Nil{}, cause: Nil{}}
+	default:
+		return ExInfo{message: fmt.Sprint(e), data: Nil{}, cause: Nil{}}
+	}
+}
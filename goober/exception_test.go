@@ -0,0 +1,42 @@
+package goober
+
+import "testing"
+
+func TestFinally(t *testing.T) {
+	var ran []Value
+	ns := DefaultNs()
+	ns.def("ran", builtin{"ran", func(vals []Value) Value {
+		ran = append(ran, vals[0])
+		return Nil{}
+	}})
+
+	assertEqual(t, test_eval_ns(ns, "(try (ran 1) :done (finally (ran 2)))"), Keyword("done"))
+	assertEqual(t, ran, []Value{Int(1), Int(2)})
+
+	// the finally clause runs after the catch clause
+	ran = nil
+	test_eval_ns(ns, "(try (throw (ex-info \"x\" {})) (catch e (ran 1)) (finally (ran 2)))")
+	assertEqual(t, ran, []Value{Int(1), Int(2)})
+
+	// and when nothing catches the exception
+	ran = nil
+	test_eval_ns(ns, "(try (try (throw (ex-info \"x\" {})) (finally (ran 1))) (catch e (ran 2)))")
+	assertEqual(t, ran, []Value{Int(1), Int(2)})
+}
+
+func TestUncaughtException(t *testing.T) {
+	defer func() {
+		e := recover()
+		assertEqual(t, e, ExInfo{message: "x", data: NewHashMap(Keyword("a"), Int(1)), cause: Nil{}})
+	}()
+	Eval(DefaultNs(), readOne("(throw (ex-info \"x\" {:a 1}))"))
+	t.Fail()
+}
+
+func TestPrnOfFailingLazySeq(t *testing.T) {
+	defer func() {
+		assertEqual(t, recover().(ExInfo).message, "arguments to '+' must be numbers: :a")
+	}()
+	Prn(test_eval("(map + [:a])"))
+	t.Fail()
+}
@@ -0,0 +1,221 @@
+package goober
+
+import "math/bits"
+
+// A persistent hash array mapped trie, the structure behind HashMap. Each
+// node covers five bits of a key's hash, and holds up to 32 slots, each
+// either an entry or a child node covering the next five bits. Only the
+// populated slots are stored, a bitmap records which ones they are.
+//
+// Nodes are never modified once built. An update copies the path from the
+// root to the slot it changes, and shares everything else with the original,
+// so updates cost O(log32 n).
+
+const (
+	hamtBits = 5
+	hamtMask = 1<<hamtBits - 1
+)
+
+type hamtNode struct {
+	bitmap uint32
+	slots  []hamtSlot
+
+	// Keys whose hashes are identical end up in the same node once all the
+	// hash bits are used up, and are kept here in insertion order.
+	collisions []hamtEntry
+}
+
+type hamtSlot struct {
+	child *hamtNode // nil if the slot holds an entry
+	entry hamtEntry
+}
+
+type hamtEntry struct {
+	hash  uint32
+	key   Value
+	value Value
+}
+
+func hamtBit(hash uint32, shift uint) uint32 {
+	return 1 << ((hash >> shift) & hamtMask)
+}
+
+// The position in the compacted slots of the slot for bit.
+func (n *hamtNode) index(bit uint32) int {
+	return bits.OnesCount32(n.bitmap & (bit - 1))
+}
+
+func (n *hamtNode) isCollision(shift uint) bool {
+	return shift >= 32
+}
+
+func (n *hamtNode) get(shift uint, hash uint32, key Value) (Value, bool) {
+	for {
+		if n.isCollision(shift) {
+			for _, e := range n.collisions {
+				if Equal(e.key, key) {
+					return e.value, true
+				}
+			}
+			return nil, false
+		}
+
+		bit := hamtBit(hash, shift)
+		if n.bitmap&bit == 0 {
+			return nil, false
+		}
+
+		slot := n.slots[n.index(bit)]
+		if slot.child == nil {
+			if slot.entry.hash == hash && Equal(slot.entry.key, key) {
+				return slot.entry.value, true
+			}
+			return nil, false
+		}
+
+		n = slot.child
+		shift += hamtBits
+	}
+}
+
+// Returns a node that also holds the entry, and whether the entry was added
+// rather than replacing an existing one.
+func (n *hamtNode) assoc(shift uint, e hamtEntry) (*hamtNode, bool) {
+
+	if n.isCollision(shift) {
+		collisions := make([]hamtEntry, 0, len(n.collisions)+1)
+		added := true
+		for _, c := range n.collisions {
+			if Equal(c.key, e.key) {
+				added = false
+				continue
+			}
+			collisions = append(collisions, c)
+		}
+		return &hamtNode{collisions: append(collisions, e)}, added
+	}
+
+	bit := hamtBit(e.hash, shift)
+	i := n.index(bit)
+
+	if n.bitmap&bit == 0 { // a free slot
+		slots := make([]hamtSlot, len(n.slots)+1)
+		copy(slots, n.slots[:i])
+		slots[i] = hamtSlot{entry: e}
+		copy(slots[i+1:], n.slots[i:])
+		return &hamtNode{bitmap: n.bitmap | bit, slots: slots}, true
+	}
+
+	slot := n.slots[i]
+	var replacement hamtSlot
+	added := true
+
+	switch {
+	case slot.child != nil:
+		child, childAdded := slot.child.assoc(shift+hamtBits, e)
+		replacement, added = hamtSlot{child: child}, childAdded
+	case slot.entry.hash == e.hash && Equal(slot.entry.key, e.key):
+		replacement, added = hamtSlot{entry: e}, false
+	default: // two entries share the slot, push them both down a level
+		child := &hamtNode{}
+		child, _ = child.assoc(shift+hamtBits, slot.entry)
+		child, _ = child.assoc(shift+hamtBits, e)
+		replacement = hamtSlot{child: child}
+	}
+
+	slots := make([]hamtSlot, len(n.slots))
+	copy(slots, n.slots)
+	slots[i] = replacement
+	return &hamtNode{bitmap: n.bitmap, slots: slots}, added
+}
+
+// Returns a node without an entry for the key, or nil if that leaves the
+// node empty, and whether there was an entry to remove.
+func (n *hamtNode) dissoc(shift uint, hash uint32, key Value) (*hamtNode, bool) {
+
+	if n.isCollision(shift) {
+		for i, c := range n.collisions {
+			if Equal(c.key, key) {
+				if len(n.collisions) == 1 {
+					return nil, true
+				}
+				collisions := make([]hamtEntry, 0, len(n.collisions)-1)
+				collisions = append(collisions, n.collisions[:i]...)
+				collisions = append(collisions, n.collisions[i+1:]...)
+				return &hamtNode{collisions: collisions}, true
+			}
+		}
+		return n, false
+	}
+
+	bit := hamtBit(hash, shift)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+
+	i := n.index(bit)
+	slot := n.slots[i]
+
+	var replacement *hamtSlot
+	if slot.child != nil {
+		child, removed := slot.child.dissoc(shift+hamtBits, hash, key)
+		if !removed {
+			return n, false
+		}
+		if child != nil {
+			replacement = &hamtSlot{child: child}
+			if e, ok := child.single(); ok {
+				replacement = &hamtSlot{entry: e} // pull a lone entry back up
+			}
+		}
+	} else if slot.entry.hash != hash || !Equal(slot.entry.key, key) {
+		return n, false
+	}
+
+	if replacement != nil {
+		slots := make([]hamtSlot, len(n.slots))
+		copy(slots, n.slots)
+		slots[i] = *replacement
+		return &hamtNode{bitmap: n.bitmap, slots: slots}, true
+	}
+
+	if len(n.slots) == 1 {
+		return nil, true
+	}
+
+	slots := make([]hamtSlot, 0, len(n.slots)-1)
+	slots = append(slots, n.slots[:i]...)
+	slots = append(slots, n.slots[i+1:]...)
+	return &hamtNode{bitmap: n.bitmap &^ bit, slots: slots}, true
+}
+
+// Returns the node's entry, if it holds exactly one entry and no children.
+func (n *hamtNode) single() (hamtEntry, bool) {
+	if len(n.collisions) == 1 {
+		return n.collisions[0], true
+	}
+	if len(n.slots) == 1 && n.slots[0].child == nil {
+		return n.slots[0].entry, true
+	}
+	return hamtEntry{}, false
+}
+
+// Calls f with each entry, in slot order, until f returns false. Reports
+// whether every entry was visited.
+func (n *hamtNode) each(f func(hamtEntry) bool) bool {
+	for _, e := range n.collisions {
+		if !f(e) {
+			return false
+		}
+	}
+	for _, slot := range n.slots {
+		if slot.child != nil {
+			if !slot.child.each(f) {
+				return false
+			}
+		} else if !f(slot.entry) {
+			return false
+		}
+	}
+	return true
+}
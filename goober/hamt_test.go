@@ -0,0 +1,66 @@
+package goober
+
+import "testing"
+
+func TestHashMapPersistence(t *testing.T) {
+	m := HashMap{}
+	versions := []HashMap{m}
+	for i := 0; i < 2000; i++ {
+		m = m.Assoc(Int(i), Int(i*2))
+		versions = append(versions, m)
+	}
+
+	for n, version := range versions {
+		if version.Count() != n {
+			t.Fatalf("version %v has count %v", n, version.Count())
+		}
+	}
+
+	old := versions[1000]
+	for i := 0; i < 2000; i++ {
+		v, ok := old.Get(Int(i))
+		if ok != (i < 1000) || (ok && v != Int(i*2)) {
+			t.Fatalf("version 1000 has %v => %v, %v", i, v, ok)
+		}
+	}
+
+	for i := 0; i < 2000; i += 2 {
+		m = m.Dissoc(Int(i))
+	}
+	assertEqual(t, m.Count(), 1000)
+	assertEqual(t, versions[2000].Count(), 2000)
+	for i := 0; i < 2000; i++ {
+		_, ok := m.Get(Int(i))
+		assertEqual(t, ok, i%2 == 1)
+	}
+
+	for i := 1; i < 2000; i += 2 {
+		m = m.Dissoc(Int(i))
+	}
+	assertEqual(t, m.Count(), 0)
+	assertEqual(t, m.root, (*hamtNode)(nil))
+}
+
+func TestHamtCollisions(t *testing.T) {
+	root := &hamtNode{}
+	keys := []Value{Str("a"), Str("b"), Str("c")}
+	for i, k := range keys {
+		root, _ = root.assoc(0, hamtEntry{hash: 42, key: k, value: Int(i)})
+	}
+	root, _ = root.assoc(0, hamtEntry{hash: 42 | 1<<31, key: Str("d"), value: Int(3)})
+
+	for i, k := range keys {
+		v, ok := root.get(0, 42, k)
+		assertEqual(t, ok, true)
+		assertEqual(t, v, Int(i))
+	}
+
+	root, removed := root.dissoc(0, 42, Str("b"))
+	assertEqual(t, removed, true)
+	_, ok := root.get(0, 42, Str("b"))
+	assertEqual(t, ok, false)
+	v, _ := root.get(0, 42, Str("c"))
+	assertEqual(t, v, Int(2))
+	v, _ = root.get(0, 42|1<<31, Str("d"))
+	assertEqual(t, v, Int(3))
+}
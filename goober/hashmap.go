@@ -0,0 +1,188 @@
+package goober
+
+import "strings"
+
+// An immutable map that accepts any value as a key, comparing keys with
+// Equal rather than with Go's ==. It is a persistent hash array mapped trie
+// (see hamt.go), so "changing" a map shares most of its structure with the
+// original rather than copying it. Iterating a map always visits its entries
+// in the same order, so printing one is stable.
+//
+// The zero value is an empty map.
+type HashMap struct {
+	root  *hamtNode
+	count int
+}
+
+// Creates a map from alternating keys and values. Later keys replace earlier
+// ones that are equal to them.
+func NewHashMap(kvs ...Value) HashMap {
+	m := HashMap{}
+	for i := 0; i+1 < len(kvs); i += 2 {
+		m = m.Assoc(kvs[i], kvs[i+1])
+	}
+	return m
+}
+
+func (m HashMap) Count() int {
+	return m.count
+}
+
+func (m HashMap) Get(k Value) (Value, bool) {
+	if m.root == nil {
+		return nil, false
+	}
+	return m.root.get(0, Hash(k), k)
+}
+
+func (m HashMap) Contains(k Value) bool {
+	_, ok := m.Get(k)
+	return ok
+}
+
+// Returns a map that also maps k to v, replacing any entry for k.
+func (m HashMap) Assoc(k, v Value) HashMap {
+	root := m.root
+	if root == nil {
+		root = &hamtNode{}
+	}
+
+	root, added := root.assoc(0, hamtEntry{hash: Hash(k), key: k, value: v})
+	if added {
+		return HashMap{root: root, count: m.count + 1}
+	}
+	return HashMap{root: root, count: m.count}
+}
+
+// Returns a map without an entry for k.
+func (m HashMap) Dissoc(k Value) HashMap {
+	if m.root == nil {
+		return m
+	}
+
+	root, removed := m.root.dissoc(0, Hash(k), k)
+	if !removed {
+		return m
+	}
+	return HashMap{root: root, count: m.count - 1}
+}
+
+type mapEntry struct {
+	key, value Value
+}
+
+// Returns the entries, always in the same order for the same keys.
+func (m HashMap) entries() []mapEntry {
+	entries := make([]mapEntry, 0, m.count)
+	if m.root != nil {
+		m.root.each(func(e hamtEntry) bool {
+			entries = append(entries, mapEntry{e.key, e.value})
+			return true
+		})
+	}
+	return entries
+}
+
+// A map is a sequence of pairs of keys and values.
+func (m HashMap) Seq() Seq {
+	return NewList(entryPairs(m.entries())...).Seq()
+}
+
+func entryPairs(entries []mapEntry) []Value {
+	pairs := make([]Value, 0, len(entries))
+	for _, e := range entries {
+		pairs = append(pairs, Sexpr([]Value{e.key, e.value}))
+	}
+	return pairs
+}
+
+func (v HashMap) truthy() bool {
+	return v.count > 0
+}
+
+func (v HashMap) prn() string {
+
+	kvs := make([]string, 0, v.count*2)
+	for _, e := range v.entries() {
+		kvs = append(kvs, e.key.prn(), e.value.prn())
+	}
+
+	return "{" + strings.Join(kvs, " ") + "}"
+}
+
+func (v HashMap) String() string {
+	return v.prn()
+}
+
+// An immutable set of values, compared with Equal. It is a map from each
+// element to itself.
+//
+// The zero value is an empty set.
+type Set struct {
+	m HashMap
+}
+
+func NewSet(elements ...Value) Set {
+	set := Set{}
+	for _, v := range elements {
+		set = set.Conj(v)
+	}
+	return set
+}
+
+func (s Set) Count() int {
+	return s.m.Count()
+}
+
+func (s Set) Contains(v Value) bool {
+	return s.m.Contains(v)
+}
+
+// Returns the element of the set equal to v, if there is one.
+func (s Set) Get(v Value) (Value, bool) {
+	return s.m.Get(v)
+}
+
+// Returns a set that also contains v.
+func (s Set) Conj(v Value) Set {
+	if s.Contains(v) {
+		return s
+	}
+	return Set{s.m.Assoc(v, v)}
+}
+
+// Returns a set without v.
+func (s Set) Disj(v Value) Set {
+	return Set{s.m.Dissoc(v)}
+}
+
+// Returns the elements in a stable order.
+func (s Set) elements() []Value {
+	entries := s.m.entries()
+	elements := make([]Value, 0, len(entries))
+	for _, e := range entries {
+		elements = append(elements, e.key)
+	}
+	return elements
+}
+
+func (s Set) Seq() Seq {
+	return NewList(s.elements()...).Seq()
+}
+
+func (v Set) truthy() bool {
+	return true
+}
+
+func (v Set) prn() string {
+	elements := make([]string, 0, v.Count())
+	for _, i := range v.elements() {
+		elements = append(elements, i.prn())
+	}
+
+	return "#{" + strings.Join(elements, " ") + "}"
+}
+
+func (v Set) String() string {
+	return v.prn()
+}
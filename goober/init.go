@@ -1,6 +1,6 @@
 package goober
 
-import "io/ioutil"
+import "io"
 import "fmt"
 import "os"
 
@@ -17,12 +17,20 @@ func init() {
 
 	path := os.Getenv("LISP_PATH")
 
-	data, err := ioutil.ReadFile(path + "/core.el")
+	f, err := os.Open(path + "/core.el")
 	if err != nil {
-		panic(fmt.Sprintf("error reading file: core.el", err))
+		panic(fmt.Sprintf("error reading file: core.el: %v", err))
 	}
-
-	for _, val := range Read(string(data)) {
+	defer f.Close()
+
+	r := NewReader("core.el", f)
+	for {
+		val, err := r.ReadForm()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			panic(fmt.Sprintf("error reading file: core.el: %v", err))
+		}
 		Eval(&world.defaultNs, val)
 	}
 }
@@ -0,0 +1,231 @@
+package goober
+
+// A sequence whose values are not computed until they are needed. A LazySeq
+// holds a function producing a Seqable, such as the body of a lazy-seq form,
+// which is called the first time the sequence is walked and then cached, so
+// it is called at most once however many times the sequence is walked. If it
+// throws, nothing is cached, and walking the sequence again calls it again.
+//
+// Lazy sequences may be infinite, as long as only a finite part of them is
+// ever walked.
+type LazySeq struct {
+	thunk func() Value // nil once realized
+	seq   Seq
+}
+
+func newLazySeq(thunk func() Value) *LazySeq {
+	return &LazySeq{thunk: thunk}
+}
+
+func (s *LazySeq) Seq() Seq {
+	if s.thunk != nil {
+		s.seq = toSeq(s.thunk())
+		s.thunk = nil
+	}
+	return s.seq
+}
+
+func (s *LazySeq) Count() int {
+	return countSeq(s.Seq())
+}
+
+func (v *LazySeq) truthy() bool {
+	return true
+}
+
+func (v *LazySeq) prn() string {
+	return prnSeq(v.Seq())
+}
+
+func (v *LazySeq) String() string {
+	return v.prn()
+}
+
+// Returns the values after the first of a Seq, without computing any of them
+// if they are lazy. Returns nil if there are none.
+func more(s Seq) Seqable {
+	if c, ok := s.(Cons); ok {
+		if c.more == nil {
+			return Nil{}
+		}
+		return c.more
+	}
+	if next := s.Next(); next != nil {
+		return next
+	}
+	return Nil{}
+}
+
+// The sequences below are the ones the builtins return, built from LazySeqs
+// whose thunks are Go functions.
+
+// Counts from start by step, stopping before end unless end is nil.
+func lazyRange(start, end, step Value) *LazySeq {
+	return newLazySeq(func() Value {
+		if end != nil {
+			c, ok := compareNumbers(start, end)
+			ascending, _ := compareNumbers(step, Int(0))
+			if !ok || ascending >= 0 && c >= 0 || ascending < 0 && c <= 0 {
+				return Nil{}
+			}
+		}
+		return Cons{start, lazyRange(addition.apply(start, step), end, step)}
+	})
+}
+
+func lazyIterate(f IFn, x Value) *LazySeq {
+	return newLazySeq(func() Value {
+		return Cons{x, newLazySeq(func() Value {
+			return lazyIterate(f, call(f, []Value{x}))
+		})}
+	})
+}
+
+// Repeats x n times, or forever if n is negative.
+func lazyRepeat(n int, x Value) *LazySeq {
+	return newLazySeq(func() Value {
+		if n == 0 {
+			return Nil{}
+		}
+		return Cons{x, lazyRepeat(n-1, x)}
+	})
+}
+
+// Walks coll over and over, starting the walk at s.
+func lazyCycle(coll Value, s Seq) *LazySeq {
+	return newLazySeq(func() Value {
+		if s == nil {
+			if s = toSeq(coll); s == nil {
+				return Nil{}
+			}
+		}
+		return Cons{s.First(), lazyCycle(coll, s.Next())}
+	})
+}
+
+func lazyTake(n int, coll Value) *LazySeq {
+	return newLazySeq(func() Value {
+		if n <= 0 {
+			return Nil{}
+		}
+		s := toSeq(coll)
+		if s == nil {
+			return Nil{}
+		}
+		return Cons{s.First(), lazyTake(n-1, more(s))}
+	})
+}
+
+func lazyDrop(n int, coll Value) *LazySeq {
+	return newLazySeq(func() Value {
+		s := toSeq(coll)
+		for ; s != nil && n > 0; n-- {
+			s = s.Next()
+		}
+		if s == nil {
+			return Nil{}
+		}
+		return s
+	})
+}
+
+func lazyTakeWhile(pred IFn, coll Value) *LazySeq {
+	return newLazySeq(func() Value {
+		s := toSeq(coll)
+		if s == nil || !call(pred, []Value{s.First()}).truthy() {
+			return Nil{}
+		}
+		return Cons{s.First(), lazyTakeWhile(pred, more(s))}
+	})
+}
+
+func lazyConcat(colls []Value) *LazySeq {
+	return newLazySeq(func() Value {
+		for ; len(colls) > 0; colls = colls[1:] {
+			if s := toSeq(colls[0]); s != nil {
+				rest := append([]Value{more(s)}, colls[1:]...)
+				return Cons{s.First(), lazyConcat(rest)}
+			}
+		}
+		return Nil{}
+	})
+}
+
+// Calls f with the first value of each collection, then the second, and so
+// on until the shortest collection runs out.
+func lazyMap(f IFn, colls []Value) *LazySeq {
+	return newLazySeq(func() Value {
+		args := make([]Value, 0, len(colls))
+		rests := make([]Value, 0, len(colls))
+		for _, coll := range colls {
+			s := toSeq(coll)
+			if s == nil {
+				return Nil{}
+			}
+			args = append(args, s.First())
+			rests = append(rests, more(s))
+		}
+		return Cons{call(f, args), lazyMap(f, rests)}
+	})
+}
+
+func lazyFilter(pred IFn, coll Value) *LazySeq {
+	return newLazySeq(func() Value {
+		for s := toSeq(coll); s != nil; s = s.Next() {
+			if call(pred, []Value{s.First()}).truthy() {
+				return Cons{s.First(), lazyFilter(pred, more(s))}
+			}
+		}
+		return Nil{}
+	})
+}
+
+// Skips the values already seen, which are kept in a persistent set so that
+// each step of the sequence has its own.
+func lazyDistinct(coll Value, seen Set) *LazySeq {
+	return newLazySeq(func() Value {
+		for s := toSeq(coll); s != nil; s = s.Next() {
+			if !seen.Contains(s.First()) {
+				return Cons{s.First(), lazyDistinct(more(s), seen.Conj(s.First()))}
+			}
+		}
+		return Nil{}
+	})
+}
+
+// Takes the first value of each collection, then the second, and so on,
+// stopping as soon as any collection runs out.
+func lazyInterleave(colls []Value) *LazySeq {
+	return newLazySeq(func() Value {
+		if len(colls) == 0 {
+			return Nil{}
+		}
+		firsts := make([]Value, 0, len(colls))
+		rests := make([]Value, 0, len(colls))
+		for _, coll := range colls {
+			s := toSeq(coll)
+			if s == nil {
+				return Nil{}
+			}
+			firsts = append(firsts, s.First())
+			rests = append(rests, more(s))
+		}
+		return lazyConcat([]Value{NewList(firsts...), lazyInterleave(rests)})
+	})
+}
+
+// Splits coll into lists of n values, starting a new list every step values.
+// A last list with fewer than n values is dropped, unless all is true.
+func lazyPartition(n, step int, all bool, coll Value) *LazySeq {
+	return newLazySeq(func() Value {
+		start := toSeq(coll)
+		part := make([]Value, 0, n)
+		for s := start; s != nil && len(part) < n; s = s.Next() {
+			part = append(part, s.First())
+		}
+		if len(part) == 0 || len(part) < n && !all {
+			return Nil{}
+		}
+		return Cons{NewList(part...), lazyPartition(n, step, all, lazyDrop(step, start))}
+	})
+}
@@ -0,0 +1,616 @@
+package goober
+
+import "io"
+import "fmt"
+import "strings"
+import "unicode"
+import "bufio"
+import "unicode/utf16"
+import "unicode/utf8"
+
+// Identifies a location in source text. Lines and columns count from 1, and
+// columns are counted in runes rather than bytes.
+type Pos struct {
+	File string
+	Line int
+	Col  int
+}
+
+func (p Pos) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Col)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Col)
+}
+
+type TokenKind int
+
+const (
+	TokenOpen            TokenKind = iota // (
+	TokenClose                            // )
+	TokenQuote                            // '
+	TokenStr                              // "...", Text holds what is between the quotes
+	TokenAtom                             // symbols, keywords, numbers, true/false/nil
+	TokenOpenVector                       // [
+	TokenCloseVector                      // ]
+	TokenOpenMap                          // {
+	TokenCloseMap                         // }
+	TokenOpenSet                          // #{
+	TokenSyntaxQuote                      // `
+	TokenUnquote                          // ~
+	TokenUnquoteSplicing                  // ~@
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case TokenOpen:
+		return "'('"
+	case TokenClose:
+		return "')'"
+	case TokenOpenVector:
+		return "'['"
+	case TokenCloseVector:
+		return "']'"
+	case TokenOpenMap:
+		return "'{'"
+	case TokenCloseMap:
+		return "'}'"
+	case TokenOpenSet:
+		return "'#{'"
+	case TokenQuote:
+		return "quote"
+	case TokenSyntaxQuote:
+		return "syntax-quote"
+	case TokenUnquote:
+		return "unquote"
+	case TokenUnquoteSplicing:
+		return "unquote-splicing"
+	case TokenStr:
+		return "string"
+	case TokenAtom:
+		return "atom"
+	default:
+		return fmt.Sprintf("token(%d)", int(k))
+	}
+}
+
+type Token struct {
+	Kind TokenKind
+	Text string
+	Pos  Pos
+}
+
+func (t Token) String() string {
+	if t.Kind == TokenStr {
+		return quoteStr(t.Text)
+	}
+	return t.Text
+}
+
+// Returns the kind of token that closes a collection opened by this kind of
+// token, or false if this kind does not open a collection.
+func (k TokenKind) closer() (TokenKind, bool) {
+	switch k {
+	case TokenOpen:
+		return TokenClose, true
+	case TokenOpenVector:
+		return TokenCloseVector, true
+	case TokenOpenMap, TokenOpenSet:
+		return TokenCloseMap, true
+	default:
+		return 0, false
+	}
+}
+
+// Returns the symbol naming the form that a prefix token such as ' wraps
+// around the form following it, or false if this kind is not a prefix.
+func (k TokenKind) wrapper() (Symbol, bool) {
+	switch k {
+	case TokenQuote:
+		return Symbol("quote"), true
+	case TokenSyntaxQuote:
+		return Symbol("syntax-quote"), true
+	case TokenUnquote:
+		return Symbol("unquote"), true
+	case TokenUnquoteSplicing:
+		return Symbol("unquote-splicing"), true
+	default:
+		return "", false
+	}
+}
+
+func (k TokenKind) isCloser() bool {
+	return k == TokenClose || k == TokenCloseVector || k == TokenCloseMap
+}
+
+type TriviaKind int
+
+const (
+	TriviaLineComment  TriviaKind = iota // ; to the end of the line
+	TriviaBlockComment                   // #| ... |#, which may nest
+	TriviaDiscard                        // #_ and the form following it
+)
+
+// Source text the lexer skips over rather than turning into tokens. Text is
+// the trivia exactly as it appeared in the source, and Pos is where it
+// starts.
+type Trivia struct {
+	Kind TriviaKind
+	Text string
+	Pos  Pos
+}
+
+// A rune-by-rune scanner that turns source text into tokens. It implements
+// TokenStream, and returns io.EOF once the input is exhausted.
+type lexer struct {
+	in     io.RuneScanner
+	pos    Pos
+	peeked *Token
+	err    error
+
+	trivia     []Trivia
+	discarding int              // how many #_ discards are being skipped
+	capture    *strings.Builder // raw text of the outermost discard
+}
+
+// Creates a TokenStream that lexes the supplied text. The file name is only
+// used to label the positions of the tokens.
+func NewLexer(file string, r io.Reader) TokenStream {
+	in, ok := r.(io.RuneScanner)
+	if !ok {
+		in = bufio.NewReader(r)
+	}
+	return newLexer(in, Pos{File: file, Line: 1, Col: 1})
+}
+
+func newLexer(in io.RuneScanner, start Pos) *lexer {
+	return &lexer{in: in, pos: start}
+}
+
+func (l *lexer) Peek() (Token, error) {
+	if l.peeked == nil {
+		t, err := l.next()
+		if err != nil {
+			return Token{}, err
+		}
+		l.peeked = &t
+	}
+	return *l.peeked, nil
+}
+
+func (l *lexer) Pop() (Token, error) {
+	t, err := l.Peek()
+	if err != nil {
+		return t, err
+	}
+	l.peeked = nil
+	return t, nil
+}
+
+func (l *lexer) readRune() (rune, error) {
+	r, _, err := l.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if l.capture != nil {
+		l.capture.WriteRune(r)
+	}
+	if r == '\n' {
+		l.pos.Line++
+		l.pos.Col = 1
+	} else {
+		l.pos.Col++
+	}
+	return r, nil
+}
+
+func (l *lexer) peekRune() (rune, error) {
+	r, _, err := l.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	l.in.UnreadRune()
+	return r, nil
+}
+
+func isWhitespace(r rune) bool {
+	return unicode.IsSpace(r) || r == ','
+}
+
+// Runes that end an atom without being part of it.
+func isDelimiter(r rune) bool {
+	switch r {
+	case '(', ')', '[', ']', '{', '}', '"', ';', '`', '~':
+		return true
+	default:
+		return isWhitespace(r)
+	}
+}
+
+// Scans the next token from the input. Once an error has been returned the
+// lexer keeps returning it.
+func (l *lexer) next() (Token, error) {
+	if l.err != nil {
+		return Token{}, l.err
+	}
+
+	t, err := l.scan()
+	if err != nil {
+		l.err = err
+	}
+	return t, err
+}
+
+func (l *lexer) scan() (Token, error) {
+	for {
+		// skip whitespace
+		var r rune
+		for {
+			var err error
+			if r, err = l.peekRune(); err != nil {
+				return Token{}, err
+			}
+			if !isWhitespace(r) {
+				break
+			}
+			l.readRune()
+		}
+
+		start := l.pos
+		l.readRune()
+
+		switch r {
+		case '(':
+			return Token{Kind: TokenOpen, Text: "(", Pos: start}, nil
+		case ')':
+			return Token{Kind: TokenClose, Text: ")", Pos: start}, nil
+		case '[':
+			return Token{Kind: TokenOpenVector, Text: "[", Pos: start}, nil
+		case ']':
+			return Token{Kind: TokenCloseVector, Text: "]", Pos: start}, nil
+		case '{':
+			return Token{Kind: TokenOpenMap, Text: "{", Pos: start}, nil
+		case '}':
+			return Token{Kind: TokenCloseMap, Text: "}", Pos: start}, nil
+		case '\'':
+			return Token{Kind: TokenQuote, Text: "'", Pos: start}, nil
+		case '`':
+			return Token{Kind: TokenSyntaxQuote, Text: "`", Pos: start}, nil
+		case '~':
+			if next, _ := l.peekRune(); next == '@' {
+				l.readRune()
+				return Token{Kind: TokenUnquoteSplicing, Text: "~@", Pos: start}, nil
+			}
+			return Token{Kind: TokenUnquote, Text: "~", Pos: start}, nil
+		case '"':
+			return l.scanStr(start)
+		case ';':
+			l.scanLineComment(start)
+		case '#':
+			switch next, _ := l.peekRune(); next {
+			case '|':
+				if err := l.scanBlockComment(start); err != nil {
+					return Token{}, err
+				}
+			case '_':
+				if err := l.scanDiscard(start); err != nil {
+					return Token{}, err
+				}
+			case '{':
+				l.readRune()
+				return Token{Kind: TokenOpenSet, Text: "#{", Pos: start}, nil
+			default:
+				return l.scanAtom(start, r)
+			}
+		default:
+			return l.scanAtom(start, r)
+		}
+	}
+}
+
+// Records trivia, unless it is part of a form being discarded, in which case
+// it is already part of the discard's text.
+func (l *lexer) addTrivia(t Trivia) {
+	if l.discarding == 0 {
+		l.trivia = append(l.trivia, t)
+	}
+}
+
+// Returns the trivia skipped since the last call.
+func (l *lexer) takeTrivia() []Trivia {
+	trivia := l.trivia
+	l.trivia = nil
+	return trivia
+}
+
+// Scans a comment running to the end of the line, the ; having been consumed.
+// The newline is left alone.
+func (l *lexer) scanLineComment(start Pos) {
+	var sb strings.Builder
+	sb.WriteRune(';')
+	for {
+		r, err := l.peekRune()
+		if err != nil || r == '\n' {
+			break
+		}
+		l.readRune()
+		sb.WriteRune(r)
+	}
+	l.addTrivia(Trivia{Kind: TriviaLineComment, Text: sb.String(), Pos: start})
+}
+
+// Scans a #| ... |# comment, the # having been consumed. Block comments nest,
+// so that code containing them can itself be commented out.
+func (l *lexer) scanBlockComment(start Pos) error {
+	var sb strings.Builder
+	sb.WriteRune('#')
+
+	depth := 0
+	prev := '#'
+	for {
+		r, err := l.readRune()
+		if err == io.EOF {
+			return &ReadError{Pos: start, Msg: "unterminated block comment starting", Expected: "'|#'", Actual: "EOF"}
+		} else if err != nil {
+			return err
+		}
+		sb.WriteRune(r)
+
+		if prev == '#' && r == '|' {
+			depth++
+			r = 0 // so that #|# does not also close the comment
+		} else if prev == '|' && r == '#' {
+			depth--
+			if depth == 0 {
+				break
+			}
+			r = 0
+		}
+		prev = r
+	}
+
+	l.addTrivia(Trivia{Kind: TriviaBlockComment, Text: sb.String(), Pos: start})
+	return nil
+}
+
+// Skips #_ and the form that follows it, the # having been consumed.
+func (l *lexer) scanDiscard(start Pos) error {
+	if l.discarding == 0 {
+		l.capture = &strings.Builder{}
+		l.capture.WriteRune('#')
+	}
+	l.discarding++
+	l.readRune() // dump _
+
+	err := l.skipForm(start)
+
+	l.discarding--
+	if l.discarding == 0 {
+		text := l.capture.String()
+		l.capture = nil
+		if err == nil {
+			l.addTrivia(Trivia{Kind: TriviaDiscard, Text: text, Pos: start})
+		}
+	}
+	return err
+}
+
+// Scans the tokens making up the next form without building anything from
+// them.
+func (l *lexer) skipForm(discard Pos) error {
+	t, err := l.scan()
+	if err == io.EOF {
+		return &ReadError{Pos: discard, Msg: "#_", Expected: "a form to discard", Actual: "EOF"}
+	} else if err != nil {
+		return err
+	}
+	return l.skipFrom(t, discard)
+}
+
+// Finishes skipping a form that starts with the supplied token.
+func (l *lexer) skipFrom(t Token, discard Pos) error {
+	if closer, ok := t.Kind.closer(); ok {
+		for {
+			next, err := l.scan()
+			if err != nil {
+				return unclosed(t, next, err, nil)
+			}
+			if next.Kind == closer {
+				return nil
+			}
+			if next.Kind.isCloser() {
+				return unclosed(t, next, nil, nil)
+			}
+			if err := l.skipFrom(next, discard); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, ok := t.Kind.wrapper(); ok {
+		return l.skipForm(discard)
+	}
+
+	if t.Kind.isCloser() {
+		return unexpected(t)
+	}
+	return nil
+}
+
+// Scans the body of a string literal, the opening quote having been consumed.
+// Escape sequences are decoded, so the token text is the string's value.
+// Strings may span lines, and a backslash at the end of a line joins it to
+// the next one, skipping the next line's indentation.
+func (l *lexer) scanStr(start Pos) (Token, error) {
+	var sb strings.Builder
+	for {
+		r, err := l.readRune()
+		if err == io.EOF {
+			return Token{}, unterminatedStr(start, sb.String())
+		} else if err != nil {
+			return Token{}, err
+		}
+
+		switch r {
+		case '"':
+			return Token{Kind: TokenStr, Text: sb.String(), Pos: start}, nil
+		case '\\':
+			if err := l.scanEscape(start, &sb); err != nil {
+				return Token{}, err
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+}
+
+var simpleEscapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'b':  '\b',
+	'f':  '\f',
+	'0':  0,
+	'"':  '"',
+	'\'': '\'',
+	'\\': '\\',
+}
+
+// Decodes an escape sequence inside a string literal, the backslash having
+// been consumed.
+func (l *lexer) scanEscape(start Pos, sb *strings.Builder) error {
+	pos := l.pos
+	pos.Col--
+
+	r, err := l.readRune()
+	if err == io.EOF {
+		return unterminatedStr(start, sb.String())
+	} else if err != nil {
+		return err
+	}
+
+	if decoded, ok := simpleEscapes[r]; ok {
+		sb.WriteRune(decoded)
+		return nil
+	}
+
+	switch r {
+	case '\n':
+		for {
+			next, err := l.peekRune()
+			if err != nil || next == '\n' || !unicode.IsSpace(next) {
+				return nil
+			}
+			l.readRune()
+		}
+	case 'u':
+		return l.scanUnicodeEscape(start, pos, sb, 4)
+	case 'U':
+		return l.scanUnicodeEscape(start, pos, sb, 8)
+	default:
+		return &ReadError{Pos: pos, Msg: "invalid escape sequence", Actual: "\\" + string(r), Form: Str(sb.String())}
+	}
+}
+
+// Decodes a \uXXXX or \UXXXXXXXX escape. A \u escape holding the high half
+// of a UTF-16 surrogate pair may be followed by another holding the low half.
+func (l *lexer) scanUnicodeEscape(start Pos, pos Pos, sb *strings.Builder, digits int) error {
+	code, err := l.scanHexDigits(start, pos, sb, digits)
+	if err != nil {
+		return err
+	}
+
+	if isHighSurrogate(code) {
+		if prefix, _ := l.peekRune(); prefix == '\\' {
+			l.readRune()
+			if r, _ := l.readRune(); r != 'u' {
+				return &ReadError{Pos: pos, Msg: "unpaired surrogate in unicode escape", Form: Str(sb.String())}
+			}
+			low, err := l.scanHexDigits(start, pos, sb, 4)
+			if err != nil {
+				return err
+			}
+			if !isLowSurrogate(low) {
+				return &ReadError{Pos: pos, Msg: "unpaired surrogate in unicode escape", Form: Str(sb.String())}
+			}
+			code = utf16.DecodeRune(code, low)
+		}
+	}
+
+	if !utf8.ValidRune(code) {
+		return &ReadError{Pos: pos, Msg: "invalid code point in unicode escape", Form: Str(sb.String())}
+	}
+
+	sb.WriteRune(code)
+	return nil
+}
+
+// Reads the hex digits of a unicode escape, returning the code they spell.
+func (l *lexer) scanHexDigits(start Pos, pos Pos, sb *strings.Builder, digits int) (rune, error) {
+	var code rune
+	for i := 0; i < digits; i++ {
+		r, err := l.readRune()
+		if err == io.EOF {
+			return 0, unterminatedStr(start, sb.String())
+		} else if err != nil {
+			return 0, err
+		}
+
+		d, ok := hexDigit(r)
+		if !ok {
+			return 0, &ReadError{
+				Pos:      pos,
+				Msg:      "invalid unicode escape",
+				Expected: "a hex digit",
+				Actual:   "'" + string(r) + "'",
+				Form:     Str(sb.String()),
+			}
+		}
+		code = code<<4 | d
+	}
+	return code, nil
+}
+
+func isHighSurrogate(r rune) bool {
+	return 0xd800 <= r && r < 0xdc00
+}
+
+func isLowSurrogate(r rune) bool {
+	return 0xdc00 <= r && r < 0xe000
+}
+
+func hexDigit(r rune) (rune, bool) {
+	switch {
+	case '0' <= r && r <= '9':
+		return r - '0', true
+	case 'a' <= r && r <= 'f':
+		return r - 'a' + 10, true
+	case 'A' <= r && r <= 'F':
+		return r - 'A' + 10, true
+	default:
+		return 0, false
+	}
+}
+
+func (l *lexer) scanAtom(start Pos, first rune) (Token, error) {
+	var sb strings.Builder
+	sb.WriteRune(first)
+	for {
+		r, err := l.peekRune()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return Token{}, err
+		}
+		if isDelimiter(r) {
+			break
+		}
+		l.readRune()
+		sb.WriteRune(r)
+	}
+	return Token{Kind: TokenAtom, Text: sb.String(), Pos: start}, nil
+}
+
+func unterminatedStr(start Pos, read string) error {
+	return &ReadError{Pos: start, Msg: "unterminated string starting", Expected: "'\"'", Actual: "EOF", Form: Str(read)}
+}
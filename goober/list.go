@@ -0,0 +1,93 @@
+package goober
+
+import "strings"
+
+// An immutable singly linked list. Code read by the reader is made of
+// Sexprs, but the lists a program builds as it runs, with list and cons and
+// the like, are Lists. Adding to the front, taking the first element and
+// taking the rest are all constant time, and a list shares its tail with the
+// list it was built from rather than copying it.
+//
+// The zero value is the empty list.
+type List struct {
+	head *cell
+}
+
+type cell struct {
+	first Value
+	rest  *cell
+	count int // of this cell and those after it, so that Count is O(1)
+}
+
+func NewList(elements ...Value) List {
+	l := List{}
+	for i := len(elements) - 1; i >= 0; i-- {
+		l = l.Cons(elements[i])
+	}
+	return l
+}
+
+func (l List) Count() int {
+	if l.head == nil {
+		return 0
+	}
+	return l.head.count
+}
+
+// Returns the first element, or nil if the list is empty.
+func (l List) First() Value {
+	if l.head == nil {
+		return Nil{}
+	}
+	return l.head.first
+}
+
+// Returns the list without its first element. The rest of an empty list is
+// the empty list.
+func (l List) Rest() List {
+	if l.head == nil {
+		return l
+	}
+	return List{l.head.rest}
+}
+
+// Returns a list with v in front of the elements of this one.
+func (l List) Cons(v Value) List {
+	return List{&cell{first: v, rest: l.head, count: l.Count() + 1}}
+}
+
+func (l List) Seq() Seq {
+	if l.head == nil {
+		return nil
+	}
+	return l
+}
+
+func (l List) Next() Seq {
+	return l.Rest().Seq()
+}
+
+func (l List) elements() []Value {
+	elements := make([]Value, 0, l.Count())
+	for c := l.head; c != nil; c = c.rest {
+		elements = append(elements, c.first)
+	}
+	return elements
+}
+
+func (v List) truthy() bool {
+	return true
+}
+
+func (v List) prn() string {
+	elements := make([]string, 0, v.Count())
+	for c := v.head; c != nil; c = c.rest {
+		elements = append(elements, c.first.prn())
+	}
+
+	return "(" + strings.Join(elements, " ") + ")"
+}
+
+func (v List) String() string {
+	return v.prn()
+}
@@ -0,0 +1,377 @@
+package goober
+
+import "fmt"
+import "math"
+import "math/big"
+import "strconv"
+import "strings"
+
+// The numeric tower. Int is the common case, and arithmetic on Ints promotes
+// to BigInt rather than overflowing. Dividing integers that do not divide
+// evenly produces a Ratio, and anything involving a Float produces a Float.
+
+type Float float64
+
+type BigInt struct {
+	v *big.Int
+}
+
+type Ratio struct {
+	v *big.Rat
+}
+
+func (v Float) truthy() bool {
+	return float64(v) != 0
+}
+
+func (v Float) prn() string {
+	f := float64(v)
+	switch {
+	case math.IsInf(f, 1):
+		return "##Inf"
+	case math.IsInf(f, -1):
+		return "##-Inf"
+	case math.IsNaN(f):
+		return "##NaN"
+	}
+
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0" // so that it reads back as a float
+	}
+	return s
+}
+
+func (v Float) String() string {
+	return v.prn()
+}
+
+func (v BigInt) truthy() bool {
+	return v.v.Sign() != 0
+}
+
+func (v BigInt) prn() string {
+	return v.v.String() + "N"
+}
+
+func (v BigInt) String() string {
+	return v.prn()
+}
+
+func (v Ratio) truthy() bool {
+	return v.v.Sign() != 0
+}
+
+func (v Ratio) prn() string {
+	return v.v.String()
+}
+
+func (v Ratio) String() string {
+	return v.prn()
+}
+
+// Numbers are ranked by how general they are, arithmetic on two numbers of
+// different ranks happens at the higher of the two.
+type numRank int
+
+const (
+	rankInt numRank = iota
+	rankBigInt
+	rankRatio
+	rankFloat
+)
+
+func rankOf(v Value) (numRank, bool) {
+	switch v.(type) {
+	case Int:
+		return rankInt, true
+	case BigInt:
+		return rankBigInt, true
+	case Ratio:
+		return rankRatio, true
+	case Float:
+		return rankFloat, true
+	default:
+		return 0, false
+	}
+}
+
+func isNumber(v Value) bool {
+	_, ok := rankOf(v)
+	return ok
+}
+
+func requireNumber(v Value, msg string) Value {
+	if !isNumber(v) {
+		panic(fmt.Sprintf(msg+": %v", v))
+	}
+	return v
+}
+
+func toBig(v Value) *big.Int {
+	switch x := v.(type) {
+	case Int:
+		return big.NewInt(int64(x))
+	case BigInt:
+		return x.v
+	default:
+		panic(fmt.Sprintf("not an integer: %v", v))
+	}
+}
+
+func toRat(v Value) *big.Rat {
+	switch x := v.(type) {
+	case Int:
+		return new(big.Rat).SetInt64(int64(x))
+	case BigInt:
+		return new(big.Rat).SetInt(x.v)
+	case Ratio:
+		return x.v
+	default:
+		panic(fmt.Sprintf("not a rational number: %v", v))
+	}
+}
+
+func toFloat(v Value) float64 {
+	switch x := v.(type) {
+	case Int:
+		return float64(x)
+	case BigInt:
+		f, _ := new(big.Float).SetInt(x.v).Float64()
+		return f
+	case Ratio:
+		f, _ := x.v.Float64()
+		return f
+	case Float:
+		return float64(x)
+	default:
+		panic(fmt.Sprintf("not a number: %v", v))
+	}
+}
+
+// Returns an Int if the integer fits in one, otherwise a BigInt.
+func normalizeBig(i *big.Int) Value {
+	if i.IsInt64() && int64(int(i.Int64())) == i.Int64() {
+		return Int(i.Int64())
+	}
+	return BigInt{i}
+}
+
+// Returns an integer if the ratio has a denominator of 1, otherwise a Ratio.
+func normalizeRat(r *big.Rat) Value {
+	if r.IsInt() {
+		return normalizeBig(new(big.Int).Set(r.Num()))
+	}
+	return Ratio{r}
+}
+
+// An arithmetic operation, defined at each rank. The int version reports
+// false if the result overflowed, in which case the operation is redone on
+// BigInts.
+type arith struct {
+	ints   func(x, y int) (int, bool)
+	bigs   func(x, y *big.Int) Value
+	ratios func(x, y *big.Rat) Value
+	floats func(x, y float64) float64
+}
+
+func (op arith) apply(x, y Value) Value {
+	rx, _ := rankOf(x)
+	ry, _ := rankOf(y)
+	if ry > rx {
+		rx = ry
+	}
+
+	switch rx {
+	case rankInt:
+		if result, ok := op.ints(int(x.(Int)), int(y.(Int))); ok {
+			return Int(result)
+		}
+		return op.bigs(toBig(x), toBig(y))
+	case rankBigInt:
+		return op.bigs(toBig(x), toBig(y))
+	case rankRatio:
+		return op.ratios(toRat(x), toRat(y))
+	default:
+		return Float(op.floats(toFloat(x), toFloat(y)))
+	}
+}
+
+var addition = arith{
+	ints: func(x, y int) (int, bool) {
+		z := x + y
+		return z, (z > x) == (y > 0)
+	},
+	bigs:   func(x, y *big.Int) Value { return BigInt{new(big.Int).Add(x, y)} },
+	ratios: func(x, y *big.Rat) Value { return normalizeRat(new(big.Rat).Add(x, y)) },
+	floats: func(x, y float64) float64 { return x + y },
+}
+
+var subtraction = arith{
+	ints: func(x, y int) (int, bool) {
+		z := x - y
+		return z, (z < x) == (y > 0)
+	},
+	bigs:   func(x, y *big.Int) Value { return BigInt{new(big.Int).Sub(x, y)} },
+	ratios: func(x, y *big.Rat) Value { return normalizeRat(new(big.Rat).Sub(x, y)) },
+	floats: func(x, y float64) float64 { return x - y },
+}
+
+var multiplication = arith{
+	ints: func(x, y int) (int, bool) {
+		if x == 0 || y == 0 {
+			return 0, true
+		}
+		z := x * y
+		return z, z/y == x && !(x == -1 && y == math.MinInt) && !(y == -1 && x == math.MinInt)
+	},
+	bigs:   func(x, y *big.Int) Value { return BigInt{new(big.Int).Mul(x, y)} },
+	ratios: func(x, y *big.Rat) Value { return normalizeRat(new(big.Rat).Mul(x, y)) },
+	floats: func(x, y float64) float64 { return x * y },
+}
+
+var division = arith{
+	ints: func(x, y int) (int, bool) {
+		if y == 0 {
+			panic("divide by zero")
+		}
+		if x%y != 0 || (x == math.MinInt && y == -1) {
+			return 0, false // let the bigs produce a ratio
+		}
+		return x / y, true
+	},
+	bigs: func(x, y *big.Int) Value {
+		if y.Sign() == 0 {
+			panic("divide by zero")
+		}
+		return normalizeRat(new(big.Rat).SetFrac(x, y))
+	},
+	ratios: func(x, y *big.Rat) Value {
+		if y.Sign() == 0 {
+			panic("divide by zero")
+		}
+		return normalizeRat(new(big.Rat).Quo(x, y))
+	},
+	floats: func(x, y float64) float64 { return x / y },
+}
+
+// Compares two numbers of any rank. Reports false if the numbers cannot be
+// ordered, which is only the case when one of them is NaN.
+func compareNumbers(x, y Value) (int, bool) {
+	rx, _ := rankOf(x)
+	ry, _ := rankOf(y)
+	if ry > rx {
+		rx = ry
+	}
+
+	switch rx {
+	case rankInt:
+		a, b := int(x.(Int)), int(y.(Int))
+		switch {
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		default:
+			return 0, true
+		}
+	case rankBigInt:
+		return toBig(x).Cmp(toBig(y)), true
+	case rankRatio:
+		return toRat(x).Cmp(toRat(y)), true
+	default:
+		a, b := toFloat(x), toFloat(y)
+		switch {
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		case a == b:
+			return 0, true
+		default:
+			return 0, false
+		}
+	}
+}
+
+// Numbers are equal when they have the same value and are both exact or
+// both floating point, so 1 equals 1N but not 1.0. Use == to compare values
+// regardless of exactness.
+func numbersEqual(x, y Value) bool {
+	rx, _ := rankOf(x)
+	ry, _ := rankOf(y)
+	if (rx == rankFloat) != (ry == rankFloat) {
+		return false
+	}
+	c, ok := compareNumbers(x, y)
+	return ok && c == 0
+}
+
+// Reports whether an atom looks like a number, which is to say it starts
+// with a digit, or with a sign followed by a digit.
+func looksNumeric(s string) bool {
+	if len(s) > 1 && (s[0] == '-' || s[0] == '+') {
+		s = s[1:]
+	}
+	return len(s) > 0 && s[0] >= '0' && s[0] <= '9'
+}
+
+// Parses a numeric literal: 42, -7, 0xFF, 0b101, 0o17, 12345678901234567890N,
+// 1/3, 1.5, 1e10, and ##Inf, ##-Inf and ##NaN.
+func parseNumber(s string) (Value, bool) {
+
+	switch s {
+	case "##Inf":
+		return Float(math.Inf(1)), true
+	case "##-Inf":
+		return Float(math.Inf(-1)), true
+	case "##NaN":
+		return Float(math.NaN()), true
+	}
+
+	body := strings.TrimLeft(s, "+-")
+	prefixed := len(body) > 2 && body[0] == '0' && strings.ContainsRune("xXbBoO", rune(body[1]))
+
+	switch {
+	case strings.HasSuffix(s, "N"):
+		i, ok := parseInteger(s[:len(s)-1], prefixed)
+		if !ok {
+			return nil, false
+		}
+		return BigInt{i}, true
+
+	case strings.Contains(s, "/"):
+		r, ok := new(big.Rat).SetString(s)
+		if !ok || strings.ContainsAny(s, ".eE") {
+			return nil, false
+		}
+		return normalizeRat(r), true
+
+	case !prefixed && strings.ContainsAny(s, ".eE"):
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, false
+		}
+		return Float(f), true
+
+	default:
+		i, ok := parseInteger(s, prefixed)
+		if !ok {
+			return nil, false
+		}
+		return normalizeBig(i), true
+	}
+}
+
+// Parses a decimal integer, or one with a 0x, 0b or 0o prefix. A plain
+// decimal with a leading zero is still decimal.
+func parseInteger(s string, prefixed bool) (*big.Int, bool) {
+	if strings.Contains(s, "_") {
+		return nil, false
+	}
+	base := 10
+	if prefixed {
+		base = 0
+	}
+	return new(big.Int).SetString(s, base)
+}
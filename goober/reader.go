@@ -2,7 +2,10 @@ package goober
 
 import "strconv"
 import "strings"
-import "errors"
+import "fmt"
+import "io"
+import "bufio"
+import "unicode"
 
 // Defines the basic union of types that can be used
 // as parameters or return values.
@@ -63,7 +66,55 @@ func (v Str) truthy() bool {
 }
 
 func (v Str) prn() string {
-	return string(v)
+	return quoteStr(string(v))
+}
+
+func (v Str) String() string {
+	return v.prn()
+}
+
+// Renders a string as a literal the reader would read back as the same
+// string, escaping quotes, backslashes and control characters.
+func quoteStr(s string) string {
+	var sb strings.Builder
+	sb.WriteRune('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString("\\\"")
+		case '\\':
+			sb.WriteString("\\\\")
+		case '\n':
+			sb.WriteString("\\n")
+		case '\t':
+			sb.WriteString("\\t")
+		case '\r':
+			sb.WriteString("\\r")
+		case '\b':
+			sb.WriteString("\\b")
+		case '\f':
+			sb.WriteString("\\f")
+		case 0:
+			sb.WriteString("\\0")
+		default:
+			if unicode.IsControl(r) {
+				fmt.Fprintf(&sb, "\\u%04x", r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteRune('"')
+	return sb.String()
+}
+
+// Renders a value the way println and str show it. This is the same as prn,
+// except that strings are shown as their contents rather than as literals.
+func display(v Value) string {
+	if s, ok := v.(Str); ok {
+		return string(s)
+	}
+	return v.prn()
 }
 
 func (v Sexpr) truthy() bool {
@@ -97,135 +148,334 @@ func (v Keyword) String() string {
 	return v.prn()
 }
 
-// Split an s-expression string into tokens
-func tokenize(s string) []string {
+// Describes input the reader could not make sense of. Pos locates the token
+// the problem is reported against, Expected and Actual describe the token
+// that was wanted and what turned up instead, and Form holds whatever part
+// of the enclosing form had been read when the reader gave up.
+type ReadError struct {
+	Pos      Pos
+	Msg      string
+	Expected string
+	Actual   string
+	Form     Value
+}
 
-	s = strings.Replace(s, ",", "", -1)
-	s = strings.Replace(s, "(", " ( ", -1)
-	s = strings.Replace(s, ")", " ) ", -1)
-	s = strings.Replace(s, "'", " ' ", -1)
-	parts := strings.Split(s, " ")
+func (e *ReadError) Error() string {
+	s := fmt.Sprintf("%s at %v", e.Msg, e.Pos)
+	if e.Expected != "" {
+		s += fmt.Sprintf(": expected %s, got %s", e.Expected, e.Actual)
+	}
+	return s
+}
 
-	tokens := make([]string, 0, len(parts))
-	for i := range parts {
-		trimmed := strings.TrimSpace(parts[i])
-		if len(trimmed) > 0 {
-			tokens = append(tokens, trimmed)
-		}
+// Describes a token for use in error messages.
+func describe(t Token, err error) string {
+	if err == io.EOF {
+		return "EOF"
+	} else if err != nil {
+		return err.Error()
 	}
-	return tokens
+	return fmt.Sprintf("'%v' at %v", t, t.Pos)
 }
 
-// Parse an s-expression value as an atom, or return nil if no atom can be derived
-func parseAtom(s string) Value {
+// Parse an s-expression value as an atom
+func parseAtom(t Token) (Value, error) {
+
+	s := t.Text
 
 	if "true" == s {
-		return Boolean(true)
+		return Boolean(true), nil
 	}
 	if "false" == s {
-		return Boolean(false)
+		return Boolean(false), nil
 	}
 	if "nil" == s {
-		return Nil{}
+		return Nil{}, nil
 	}
 
-	if ival, err := strconv.Atoi(s); err == nil {
-		return Int(ival)
-	} else if len(s) > 1 && strings.HasPrefix(s, "\"") && strings.HasSuffix(s, "\"") {
-		i := s[1 : len(s)-1]
-		return Str(i)
+	if looksNumeric(s) || strings.HasPrefix(s, "##") {
+		if n, ok := parseNumber(s); ok {
+			return n, nil
+		}
+		return nil, &ReadError{Pos: t.Pos, Msg: "invalid number", Actual: s}
+	} else if s == ":" {
+		return nil, &ReadError{Pos: t.Pos, Msg: "invalid keyword", Actual: s}
 	} else if strings.HasPrefix(s, ":") {
-		return Keyword(s[1:])
+		return Keyword(s[1:]), nil
 	} else if len(s) > 0 {
-		return Symbol(s)
+		return Symbol(s), nil
 	} else {
-		panic("not a valid atom: " + s)
+		return nil, &ReadError{Pos: t.Pos, Msg: "not a valid atom", Actual: s}
 	}
 }
 
-// Useful for 'consuming' from the head of a slice. Returns the popped value
-// from the slice, and updates the supplied slice pointer to not include the
-// popped value.
+// Useful for 'consuming' tokens from the head of a stream of tokens, as
+// produced by the lexer.
 type TokenStream interface {
-	Peek() (string, error)
-	Pop() (string, error)
+	Peek() (Token, error)
+	Pop() (Token, error)
 }
 
-// The inner version of parse, takes a pointer to a slice of tokens.
-// The slice is modified as the parsing logic consumes the tokens.
-// Returns a pointer to a Value.
-func Parse(ts TokenStream) Value {
+// Reads a single form from a stream of tokens.
+// The stream is modified as the parsing logic consumes the tokens. Returns
+// io.EOF if the stream holds no more tokens, or a *ReadError if the tokens
+// do not make up a valid form.
+func Parse(ts TokenStream) (Value, error) {
 
 	token, err := ts.Pop()
 	if err != nil {
-		panic("no tokens supplied")
+		return nil, err
 	}
 
-	if token == "(" {
-		elements := make([]Value, 0)
-		for {
-			if next, _ := ts.Peek(); next == ")" {
-				ts.Pop() // dump )
-				return Sexpr(elements)
-			} else {
-				v := Parse(ts)
-				elements = append(elements, v)
-			}
+	switch token.Kind {
+	case TokenOpen:
+		elements, err := parseElements(ts, token)
+		if err != nil {
+			return nil, err
+		}
+		return setPosition(elements, token.Pos), nil
+	case TokenOpenVector:
+		elements, err := parseElements(ts, token)
+		if err != nil {
+			return nil, err
+		}
+		return NewVector(elements...), nil
+	case TokenOpenMap:
+		elements, err := parseElements(ts, token)
+		if err != nil {
+			return nil, err
+		}
+		return parseMap(token, elements)
+	case TokenOpenSet:
+		elements, err := parseElements(ts, token)
+		if err != nil {
+			return nil, err
+		}
+		return parseSet(token, elements)
+	case TokenClose, TokenCloseVector, TokenCloseMap:
+		return nil, unexpected(token)
+	case TokenQuote, TokenSyntaxQuote, TokenUnquote, TokenUnquoteSplicing:
+		wrapper, _ := token.Kind.wrapper()
+		v, err := Parse(ts)
+		if err == io.EOF {
+			return nil, &ReadError{Pos: token.Pos, Msg: string(wrapper), Expected: "a form", Actual: "EOF"}
+		} else if err != nil {
+			return nil, err
 		}
+		return Sexpr([]Value{wrapper, v}), nil
+	case TokenStr:
+		return Str(token.Text), nil
+	default:
+		return parseAtom(token)
 	}
+}
 
-	val := parseAtom(token)
+// Reads the elements of a collection up to the token that closes it, the
+// opening token having been consumed.
+func parseElements(ts TokenStream, open Token) ([]Value, error) {
+	closer, _ := open.Kind.closer()
 
-	switch val := val.(type) {
-	case Symbol:
-		if string(val) == "'" {
-			return Sexpr([]Value{Symbol("quote"), Parse(ts)})
+	elements := make([]Value, 0)
+	for {
+		next, err := ts.Peek()
+		if err != nil {
+			return nil, unclosed(open, next, err, partial(open, elements))
 		}
-	default:
-		return val
+		if next.Kind == closer {
+			ts.Pop() // dump the closer
+			return elements, nil
+		}
+		if next.Kind.isCloser() {
+			return nil, unclosed(open, next, nil, partial(open, elements))
+		}
+
+		v, err := Parse(ts)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, v)
+	}
+}
+
+// Wraps the elements read so far for a ReadError.
+func partial(open Token, elements []Value) Value {
+	if open.Kind == TokenOpen {
+		return Sexpr(elements)
+	}
+	return NewVector(elements...)
+}
+
+var collectionNames = map[TokenKind]string{
+	TokenOpen:       "paren",
+	TokenOpenVector: "bracket",
+	TokenOpenMap:    "brace",
+	TokenOpenSet:    "set",
+}
+
+// Describes a collection that was not closed before the input ended, or
+// before a token that closes some other kind of collection.
+func unclosed(open Token, next Token, err error, form Value) error {
+	if _, ok := err.(*ReadError); ok {
+		return err
+	}
+	closer, _ := open.Kind.closer()
+	return &ReadError{
+		Pos:      open.Pos,
+		Msg:      "unclosed " + collectionNames[open.Kind] + " opened",
+		Expected: closer.String(),
+		Actual:   describe(next, err),
+		Form:     form,
 	}
+}
 
-	return val
+func unexpected(t Token) error {
+	return &ReadError{Pos: t.Pos, Msg: fmt.Sprintf("unexpected '%v'", t), Actual: t.Text}
+}
+
+func parseMap(open Token, elements []Value) (Value, error) {
+	if len(elements)%2 != 0 {
+		return nil, &ReadError{Pos: open.Pos, Msg: "map literal must contain an even number of forms, in the literal opened", Form: NewVector(elements...)}
+	}
+
+	m := HashMap{}
+	for i := 0; i < len(elements); i += 2 {
+		k := elements[i]
+		if m.Contains(k) {
+			return nil, &ReadError{Pos: open.Pos, Msg: fmt.Sprintf("duplicate key %v in the map literal opened", k.prn()), Form: NewVector(elements...)}
+		}
+		m = m.Assoc(k, elements[i+1])
+	}
+	return m, nil
+}
+
+func parseSet(open Token, elements []Value) (Value, error) {
+	set := Set{}
+	for _, v := range elements {
+		if set.Contains(v) {
+			return nil, &ReadError{Pos: open.Pos, Msg: fmt.Sprintf("duplicate element %v in the set literal opened", v.prn()), Form: NewVector(elements...)}
+		}
+		set = set.Conj(v)
+	}
+	return set, nil
 }
 
 type stringStream struct {
-	tokens []string
+	tokens []Token
 }
 
-func (s *stringStream) Peek() (string, error) {
+func (s *stringStream) Peek() (Token, error) {
 	if len(s.tokens) == 0 {
-		return "", errors.New("stream is empty")
+		return Token{}, io.EOF
 	}
 	first := s.tokens[0]
 	return first, nil
 }
 
-func (s *stringStream) Pop() (string, error) {
+func (s *stringStream) Pop() (Token, error) {
 	if len(s.tokens) == 0 {
-		return "", errors.New("stream is empty")
+		return Token{}, io.EOF
 	}
 	first, rest := s.tokens[0], s.tokens[1:]
 	s.tokens = rest
 	return first, nil
 }
 
-func NewTokenStream(tokens ...string) TokenStream {
+func NewTokenStream(tokens ...Token) TokenStream {
 	return &stringStream{tokens: tokens}
 }
 
+// Reads every remaining form from the token stream.
+func ReadAll(ts TokenStream) ([]Value, error) {
+	vals := make([]Value, 0)
+	for {
+		v, err := Parse(ts)
+		if err == io.EOF {
+			break // out of tokens
+		} else if err != nil {
+			return vals, err
+		}
+		vals = append(vals, v)
+	}
+
+	return vals, nil
+}
+
 // The reader function to use when you want to read series of s-expressions in
 // a string into Value data structures.
-func Read(s string) []Value {
-	tokens := tokenize(s)
-	ts := NewTokenStream(tokens...)
+func Read(s string) ([]Value, error) {
+	return ReadAll(NewLexer("", strings.NewReader(s)))
+}
 
-	vals := make([]Value, 0)
+// Reads forms one at a time from an io.Reader, only consuming as much input
+// as it needs to complete each form. This makes it suitable for reading
+// scripts incrementally, or for reading forms from a terminal or network
+// connection as they arrive.
+//
+// A Reader is also a TokenStream, so it can be handed to Parse directly.
+type Reader struct {
+	in    *bufio.Reader
+	lexer *lexer
+}
+
+// Creates a Reader over the supplied input. The file name is used to label
+// the positions of what is read. A leading "#!" line is skipped, so that
+// executable scripts can be read directly.
+func NewReader(file string, r io.Reader) *Reader {
+	in := bufio.NewReader(r)
+	return &Reader{in: in, lexer: newLexer(in, Pos{File: file, Line: 1, Col: 1})}
+}
+
+// Skips the "#!" line, if there is one. This is put off until the first
+// token is wanted so that creating a Reader never blocks on its input.
+func (r *Reader) start() {
+	if r.in == nil {
+		return
+	}
+	if prefix, _ := r.in.Peek(2); string(prefix) == "#!" {
+		r.in.ReadString('\n')
+		r.lexer.pos.Line++
+	}
+	r.in = nil
+}
+
+func (r *Reader) Peek() (Token, error) {
+	r.start()
+	return r.lexer.Peek()
+}
+
+func (r *Reader) Pop() (Token, error) {
+	r.start()
+	return r.lexer.Pop()
+}
+
+// Returns the comments and discarded forms skipped over since the last call,
+// in the order they appeared.
+func (r *Reader) Trivia() []Trivia {
+	return r.lexer.takeTrivia()
+}
+
+// Reads the next top-level form. Returns io.EOF once the input is exhausted,
+// or a *ReadError if the input is malformed.
+func (r *Reader) ReadForm() (Value, error) {
+	return Parse(r)
+}
+
+// Discards the rest of the line the reader is on, along with the error it
+// stopped at, if any, so that reading can go on after malformed input, as it
+// does in a REPL.
+func (r *Reader) SkipLine() {
+	r.start()
+	l := r.lexer
+	l.err, l.peeked = nil, nil
+	l.trivia, l.discarding, l.capture = nil, 0, nil
 	for {
-		if _, err := ts.Peek(); err != nil {
-			break // out of tokens
+		if c, err := l.readRune(); err != nil || c == '\n' {
+			return
 		}
-		vals = append(vals, Parse(ts))
 	}
+}
 
-	return vals
+// Reads every remaining form.
+func (r *Reader) ReadAll() ([]Value, error) {
+	return ReadAll(r)
 }
@@ -3,6 +3,8 @@ package goober
 import "testing"
 import "fmt"
 import "reflect"
+import "strings"
+import "io"
 
 func assertEqual(t *testing.T, a interface{}, b interface{}) {
 	if !reflect.DeepEqual(a, b) {
@@ -20,7 +22,19 @@ func sym(s string) Symbol {
 }
 
 func readOne(s string) Value {
-	return Read(s)[0]
+	vals, err := Read(s)
+	if err != nil {
+		panic(err)
+	}
+	return vals[0]
+}
+
+func readErr(s string) *ReadError {
+	_, err := Read(s)
+	if e, ok := err.(*ReadError); ok {
+		return e
+	}
+	panic(fmt.Sprintf("expected a read error for %v, got %v", s, err))
 }
 
 func TestReadBoolean(t *testing.T) {
@@ -52,10 +66,265 @@ func TestReadQuote(t *testing.T) {
 
 func doPop(ts TokenStream) string {
 	x, _ := ts.Pop()
-	return x
+	return x.Text
+}
+
+func atom(s string) Token {
+	return Token{Kind: TokenAtom, Text: s}
 }
 
 func TestTokenStream(t *testing.T) {
-	ts := NewTokenStream("a", "b", "c")
+	ts := NewTokenStream(atom("a"), atom("b"), atom("c"))
 	assertEqual(t, []string{doPop(ts), doPop(ts), doPop(ts)}, []string{"a", "b", "c"})
 }
+
+func lexAll(s string) []Token {
+	ts := NewLexer("test.el", strings.NewReader(s))
+	tokens := make([]Token, 0)
+	for {
+		t, err := ts.Pop()
+		if err != nil {
+			return tokens
+		}
+		tokens = append(tokens, t)
+	}
+}
+
+func TestLexPositions(t *testing.T) {
+	tokens := lexAll("(foo\n  'bar)")
+	assertEqual(t, tokens, []Token{
+		{Kind: TokenOpen, Text: "(", Pos: Pos{File: "test.el", Line: 1, Col: 1}},
+		{Kind: TokenAtom, Text: "foo", Pos: Pos{File: "test.el", Line: 1, Col: 2}},
+		{Kind: TokenQuote, Text: "'", Pos: Pos{File: "test.el", Line: 2, Col: 3}},
+		{Kind: TokenAtom, Text: "bar", Pos: Pos{File: "test.el", Line: 2, Col: 4}},
+		{Kind: TokenClose, Text: ")", Pos: Pos{File: "test.el", Line: 2, Col: 7}},
+	})
+}
+
+func TestLexStr(t *testing.T) {
+	tokens := lexAll(`"hello, (world)" "a\"b"`)
+	assertEqual(t, tokens[0].Text, "hello, (world)")
+	assertEqual(t, tokens[1].Text, `a"b`)
+	assertEqual(t, len(tokens), 2)
+}
+
+func TestLexCommas(t *testing.T) {
+	assertEqual(t, readOne("(1,2, 3)"), sexpr(Int(1), Int(2), Int(3)))
+}
+
+func TestReadStrWhitespace(t *testing.T) {
+	vals, _ := Read(`"hello world" x`)
+	assertEqual(t, vals, []Value{Str("hello world"), sym("x")})
+}
+
+func TestReadErrors(t *testing.T) {
+	e := readErr("(a\n  (b c)\n  (d")
+	assertEqual(t, e.Pos, Pos{Line: 3, Col: 3})
+	assertEqual(t, e.Form, sexpr(sym("d")))
+	assertEqual(t, e.Error(), "unclosed paren opened at 3:3: expected ')', got EOF")
+
+	e = readErr("(a))")
+	assertEqual(t, e.Pos, Pos{Line: 1, Col: 4})
+	assertEqual(t, e.Msg, "unexpected ')'")
+
+	e = readErr(`(str "abc`)
+	assertEqual(t, e.Pos, Pos{Line: 1, Col: 6})
+	assertEqual(t, e.Form, Str("abc"))
+
+	e = readErr("'")
+	assertEqual(t, e.Expected, "a form")
+}
+
+func TestReadEmpty(t *testing.T) {
+	vals, err := Read("  ")
+	assertEqual(t, vals, []Value{})
+	assertEqual(t, err, nil)
+}
+
+func TestReaderIncremental(t *testing.T) {
+	pr, pw := io.Pipe()
+	r := NewReader("pipe", pr)
+
+	go pw.Write([]byte("(+ 1\n 2) "))
+	v, err := r.ReadForm() // must not wait for more input than the form needs
+	assertEqual(t, v, sexpr(sym("+"), Int(1), Int(2)))
+	assertEqual(t, err, nil)
+
+	go func() {
+		pw.Write([]byte("x"))
+		pw.Close()
+	}()
+	v, err = r.ReadForm()
+	assertEqual(t, v, sym("x"))
+	assertEqual(t, err, nil)
+
+	_, err = r.ReadForm()
+	assertEqual(t, err, io.EOF)
+}
+
+func TestReaderSkipLine(t *testing.T) {
+	r := NewReader("repl", strings.NewReader("\"\\q\" 1\n(a #_)\n:ok"))
+
+	_, first := r.ReadForm()
+	_, err := r.ReadForm() // the lexer's error sticks until the line is skipped
+	assertEqual(t, err, first)
+
+	r.SkipLine()
+	_, err = r.ReadForm()
+	assertEqual(t, err.(*ReadError).Msg, "unexpected ')'")
+
+	r.SkipLine()
+	v, err := r.ReadForm()
+	assertEqual(t, v, Keyword("ok"))
+	assertEqual(t, err, nil)
+}
+
+func TestReaderShebang(t *testing.T) {
+	r := NewReader("script.el", strings.NewReader("#!./repl\n\n(println 1)"))
+	tok, _ := r.Peek()
+	assertEqual(t, tok.Pos, Pos{File: "script.el", Line: 3, Col: 1})
+	vals, _ := r.ReadAll()
+	assertEqual(t, vals, []Value{sexpr(sym("println"), Int(1))})
+}
+
+func TestReadStrEscapes(t *testing.T) {
+	assertEqual(t, readOne(`"a\nb\tc\\d\"e\'f"`), Str("a\nb\tc\\d\"e'f"))
+	assertEqual(t, readOne(`"é\U0001F600😀"`), Str("é😀😀"))
+	assertEqual(t, readOne("\"one\ntwo\""), Str("one\ntwo"))
+	assertEqual(t, readOne("\"one \\\n    two\""), Str("one two"))
+
+	e := readErr(`"abc\qdef"`)
+	assertEqual(t, e.Pos, Pos{Line: 1, Col: 5})
+	assertEqual(t, e.Actual, `\q`)
+
+	e = readErr(`"\u12x4"`)
+	assertEqual(t, e.Expected, "a hex digit")
+
+	assertEqual(t, readOne(`"\uD83D\uDE00"`), Str("😀"))
+
+	e = readErr(`"\uD83D"`)
+	assertEqual(t, e.Msg, "invalid code point in unicode escape")
+	e = readErr(`"\uD83D\u0041"`)
+	assertEqual(t, e.Msg, "unpaired surrogate in unicode escape")
+}
+
+func TestPrnStrRoundTrip(t *testing.T) {
+	for _, s := range []string{"plain", "a\"b", `back\slash`, "tab\tnew\nline", "nul\x00bell\x07", "é😀"} {
+		printed := Str(s).prn()
+		assertEqual(t, readOne(printed), Str(s))
+	}
+	assertEqual(t, Str("a\"b\n").prn(), `"a\"b\n"`)
+	assertEqual(t, sexpr(Str("x"), Int(1)).prn(), `("x" 1)`)
+}
+
+func TestReadComments(t *testing.T) {
+	vals, err := Read(`
+;; leading comment
+(a ; trailing comment
+ b) #| block #| nested |# (
+ |# c
+#_ (d (e) 'f) g #_ #_ h i j`)
+	assertEqual(t, err, nil)
+	assertEqual(t, vals, []Value{sexpr(sym("a"), sym("b")), sym("c"), sym("g"), sym("j")})
+
+	assertEqual(t, readOne("(a;comment\n)"), sexpr(sym("a")))
+	assertEqual(t, readOne("foo#bar"), sym("foo#bar"))
+
+	assertEqual(t, readErr("#| open").Msg, "unterminated block comment starting")
+	assertEqual(t, readErr("(a #_)").Msg, "unexpected ')'")
+	assertEqual(t, readErr("#_").Expected, "a form to discard")
+}
+
+func TestReadTrivia(t *testing.T) {
+	r := NewReader("t.el", strings.NewReader("; one\n#_(x ; two\n) #| three |# y ; four"))
+
+	v, _ := r.ReadForm()
+	assertEqual(t, v, sym("y"))
+	assertEqual(t, r.Trivia(), []Trivia{
+		{Kind: TriviaLineComment, Text: "; one", Pos: Pos{File: "t.el", Line: 1, Col: 1}},
+		{Kind: TriviaDiscard, Text: "#_(x ; two\n)", Pos: Pos{File: "t.el", Line: 2, Col: 1}},
+		{Kind: TriviaBlockComment, Text: "#| three |#", Pos: Pos{File: "t.el", Line: 3, Col: 3}},
+	})
+
+	_, err := r.ReadForm()
+	assertEqual(t, err, io.EOF)
+	assertEqual(t, r.Trivia(), []Trivia{
+		{Kind: TriviaLineComment, Text: "; four", Pos: Pos{File: "t.el", Line: 3, Col: 17}},
+	})
+}
+
+func TestReadCollections(t *testing.T) {
+	assertEqual(t, readOne("[a [1] ()]"), NewVector(sym("a"), NewVector(Int(1)), Sexpr{}))
+	assertEqual(t, readOne("{:a 1, :b [2]}"), NewHashMap(Keyword("a"), Int(1), Keyword("b"), NewVector(Int(2))))
+	assertEqual(t, readOne("#{1 :x}"), NewSet(Int(1), Keyword("x")))
+	assertEqual(t, readOne("{}"), HashMap{})
+	assertEqual(t, readOne("'[x]"), sexpr(sym("quote"), NewVector(sym("x"))))
+
+	assertEqual(t, NewVector(Int(1), Str("a")).prn(), `[1 "a"]`)
+	assertEqual(t, NewHashMap(Keyword("a"), NewVector()).prn(), "{:a []}")
+	assertEqual(t, NewSet(Int(1)).prn(), "#{1}")
+
+	e := readErr("(a [b)")
+	assertEqual(t, e.Error(), "unclosed bracket opened at 1:4: expected ']', got ')' at 1:6")
+	assertEqual(t, e.Form, NewVector(sym("b")))
+
+	assertEqual(t, readErr("{:a}").Msg, "map literal must contain an even number of forms, in the literal opened")
+	assertEqual(t, readErr("{:a 1 :a 2}").Msg, "duplicate key :a in the map literal opened")
+	assertEqual(t, readErr("#{1 1}").Msg, "duplicate element 1 in the set literal opened")
+	assertEqual(t, readErr("#{1 ]").Msg, "unclosed set opened")
+	assertEqual(t, readErr("]").Msg, "unexpected ']'")
+	assertEqual(t, readErr("#_[a (b]").Msg, "unclosed paren opened")
+}
+
+func TestReadSyntaxQuote(t *testing.T) {
+	assertEqual(t, readOne("`(a ~b ~@c)"), sexpr(sym("syntax-quote"),
+		sexpr(sym("a"), sexpr(sym("unquote"), sym("b")), sexpr(sym("unquote-splicing"), sym("c")))))
+	assertEqual(t, readOne("~[x]"), sexpr(sym("unquote"), NewVector(sym("x"))))
+	assertEqual(t, readOne("(a~b)"), sexpr(sym("a"), sexpr(sym("unquote"), sym("b"))))
+	assertEqual(t, readErr("`").Msg, "syntax-quote")
+}
+
+func TestReadNumbers(t *testing.T) {
+	assertEqual(t, readOne("-7"), Int(-7))
+	assertEqual(t, readOne("+7"), Int(7))
+	assertEqual(t, readOne("007"), Int(7))
+	assertEqual(t, readOne("0xFF"), Int(255))
+	assertEqual(t, readOne("-0x10"), Int(-16))
+	assertEqual(t, readOne("0b101"), Int(5))
+	assertEqual(t, readOne("0o17"), Int(15))
+	assertEqual(t, readOne("1.5"), Float(1.5))
+	assertEqual(t, readOne("1e10"), Float(1e10))
+	assertEqual(t, readOne("-2.5e-3"), Float(-2.5e-3))
+	assertEqual(t, readOne("4/2"), Int(2))
+	assertEqual(t, readOne("-"), sym("-"))
+	assertEqual(t, readOne("->x"), sym("->x"))
+
+	assertEqual(t, readOne("12345678901234567890N").prn(), "12345678901234567890N")
+	assertEqual(t, readOne("12345678901234567890").prn(), "12345678901234567890N")
+	assertEqual(t, readOne("5N").prn(), "5N")
+	assertEqual(t, readOne("2/6").prn(), "1/3")
+	assertEqual(t, readOne("-1/3").prn(), "-1/3")
+	assertEqual(t, readOne("##Inf").prn(), "##Inf")
+	assertEqual(t, readOne("##-Inf").prn(), "##-Inf")
+	assertEqual(t, readOne("##NaN").prn(), "##NaN")
+
+	assertEqual(t, Float(2).prn(), "2.0")
+	assertEqual(t, Float(1e21).prn(), "1e+21")
+	assertEqual(t, readOne(Float(0.1).prn()), Float(0.1))
+
+	for _, s := range []string{"1abc", "1/0", "1.5/2", "0xZZ", "1.5N", "1_000", "##Foo"} {
+		assertEqual(t, readErr(s).Msg, "invalid number")
+	}
+}
+
+func TestReadAnyKey(t *testing.T) {
+	m := readOne("{(1 2) :list, [3] :vector, {:k 1} :map, #{4} :set, 1.5 :float}").(HashMap)
+	for _, pair := range [][2]string{{"[1 2]", ":list"}, {"(3)", ":vector"}, {"{:k 1}", ":map"}, {"#{4}", ":set"}, {"1.5", ":float"}} {
+		v, ok := m.Get(readOne(pair[0]))
+		assertEqual(t, ok, true)
+		assertEqual(t, v, readOne(pair[1]))
+	}
+
+	assertEqual(t, readErr("{(1) 1 [1] 2}").Msg, "duplicate key [1] in the map literal opened")
+	assertEqual(t, readOne("{:b 2 :a 1 :c 3}").prn(), readOne("{:c 3 :a 1 :b 2}").prn())
+}
@@ -0,0 +1,147 @@
+package goober
+
+import "fmt"
+import "strings"
+
+// recur jumps back to the start of the nearest enclosing fn or loop with new
+// values for its parameters or bindings. It does this by returning a recur
+// value, which only reaches the fn or loop intact if nothing is done with it
+// on the way, so a recur has to be in tail position: the last form of the
+// body, or of an if, do, let or loop in tail position, or of what a macro in
+// tail position expands to.
+//
+// Whether every recur in a fn or loop is in tail position, and passes the
+// right number of values, is checked when the fn or loop form is evaluated,
+// before its body ever runs, so a misplaced recur is reported even if it
+// would never be reached. The exception is the recurs macros expand to, which
+// are checked as the macros are expanded, with the fn or loop they are in
+// kept in the context for that.
+
+// What a recur jumps back to. The zero recurTarget is nothing.
+type recurTarget struct {
+	name     string // "fn" or "loop", for errors
+	arity    int
+	variadic bool // whether it takes more than arity values
+}
+
+func (args argsInfo) recurTarget() recurTarget {
+	return recurTarget{name: "fn", arity: len(args.declared), variadic: args.useRest}
+}
+
+func checkRecur(context *context, body []Value, target recurTarget) {
+	checkRecurBody(context, body, target, true)
+}
+
+// Checks what a macro expanded to, in the fn or loop it was expanded in.
+func checkRecurExpansion(context *context, expanded Value, tail bool) {
+	if context.recur.name != "" {
+		checkRecurForm(context, expanded, context.recur, tail)
+	}
+}
+
+// Checks forms evaluated in order, the last of which is in tail position if
+// the forms are.
+func checkRecurBody(context *context, forms []Value, target recurTarget, tail bool) {
+	for i, form := range forms {
+		checkRecurForm(context, form, target, tail && i == len(forms)-1)
+	}
+}
+
+func checkRecurForm(context *context, v Value, target recurTarget, tail bool) {
+
+	switch v := v.(type) {
+	case Sexpr:
+		if len(v) == 0 {
+			return
+		}
+
+		head, _ := v[0].(Symbol)
+		switch head {
+		case "quote", "syntax-quote", "defmacro":
+			// data, or checked when it is evaluated
+		case "recur":
+			if !tail {
+				panic(fmt.Sprintf("recur must be in tail position: %v", v))
+			}
+			n := len(v) - 1
+			if n < target.arity || n > target.arity && !target.variadic {
+				panic(fmt.Sprintf("recur passes %v values to a %v that takes %v: %v", n, target.name, target.arity, v))
+			}
+			checkRecurBody(context, v[1:], target, false)
+		case "if":
+			if len(v) > 1 {
+				checkRecurForm(context, v[1], target, false)
+				for _, branch := range v[2:] {
+					checkRecurForm(context, branch, target, tail)
+				}
+			}
+		case "do":
+			checkRecurBody(context, v[1:], target, tail)
+		case "let":
+			if len(v) > 1 {
+				checkRecurBindings(context, v[1], target)
+				checkRecurBody(context, v[2:], target, tail)
+			}
+		case "loop":
+			if len(v) > 1 {
+				n := checkRecurBindings(context, v[1], target)
+				checkRecur(context, v[2:], recurTarget{name: "loop", arity: n})
+			}
+		case "fn":
+			if len(v) > 1 {
+				checkRecur(context, v[2:], getArgs(v[1]).recurTarget())
+			}
+		case "try":
+			// a recur can't jump out of a try, which would skip its finally
+			for _, form := range v[1:] {
+				clause, _ := form.(Sexpr)
+				if len(clause) > 0 && (clause[0] == Symbol("catch") || clause[0] == Symbol("finally")) {
+					checkRecurBody(context, clause[1:], target, false)
+				} else {
+					checkRecurForm(context, form, target, false)
+				}
+			}
+		default:
+			if !isMacroName(context, head) { // macros are checked as they are expanded
+				checkRecurBody(context, v, target, false)
+			}
+		}
+
+	case Vector:
+		checkRecurBody(context, v.elements(), target, false)
+
+	case HashMap:
+		for _, e := range v.entries() {
+			checkRecurForm(context, e.key, target, false)
+			checkRecurForm(context, e.value, target, false)
+		}
+
+	case Set:
+		checkRecurBody(context, v.elements(), target, false)
+
+	case Seq:
+		checkRecurForm(context, Sexpr(seqElements(v)), target, tail)
+	}
+}
+
+// Checks the values of a let or loop's bindings, none of which are in tail
+// position, returning how many bindings there are.
+func checkRecurBindings(context *context, v Value, target recurTarget) int {
+	bindings := requireBindings(v, "bindings must be a list or vector")
+	for i := 1; i < len(bindings); i += 2 {
+		checkRecurForm(context, bindings[i], target, false)
+	}
+	return len(bindings) / 2
+}
+
+// Whether a symbol names a macro, rather than anything else, or nothing yet.
+func isMacroName(context *context, sym Symbol) bool {
+	if sym == "" || specials[string(sym)] != nil || builtinMap[string(sym)] != nil {
+		return false
+	}
+	if isQualified(sym) { // as syntax-quote leaves the macros it refers to
+		sym = sym[strings.Index(string(sym), "/")+1:]
+	}
+	f, ok := context.ns.vars[string(sym)].(fn)
+	return ok && f.isMacro
+}
@@ -0,0 +1,194 @@
+package goober
+
+import "fmt"
+import "strings"
+import "unicode/utf8"
+
+// Seqable is implemented by anything that can be walked as a sequence of
+// values: every collection, strings, and nil. The sequence functions, such
+// as first, rest, nth and count, are written in terms of it, so any type
+// that implements it works with them.
+type Seqable interface {
+	Value
+
+	// Returns the values as a Seq, or nil if there are none.
+	Seq() Seq
+
+	Count() int
+}
+
+// A Seq is a non-empty sequence, walked by taking its first value and then
+// the Seq of the values after it.
+type Seq interface {
+	Seqable
+	First() Value
+
+	// Returns the values after the first, or nil if there are none.
+	Next() Seq
+}
+
+// Returns the Seq of a Seqable value, or nil if it is empty.
+func toSeq(v Value) Seq {
+	switch x := v.(type) {
+	case Seqable:
+		return x.Seq()
+	default:
+		panic(fmt.Sprintf("not seq-able: %v", v))
+	}
+}
+
+// Returns every value in a Seqable.
+func seqElements(v Value) []Value {
+	var elements []Value
+	for s := toSeq(v); s != nil; s = s.Next() {
+		elements = append(elements, s.First())
+	}
+	return elements
+}
+
+// Counts a Seq by walking it, for the Seqs that don't know their length.
+func countSeq(s Seq) int {
+	n := 0
+	for ; s != nil; s = s.Next() {
+		n++
+	}
+	return n
+}
+
+func prnSeq(s Seq) string {
+	elements := make([]string, 0)
+	for ; s != nil; s = s.Next() {
+		elements = append(elements, s.First().prn())
+	}
+
+	return "(" + strings.Join(elements, " ") + ")"
+}
+
+// A value in front of a sequence, which is what consing onto anything other
+// than a List produces. The sequence it is in front of is not walked or
+// copied, so it may be lazy.
+type Cons struct {
+	first Value
+	more  Seqable // nil if there is nothing after first
+}
+
+func (c Cons) Seq() Seq {
+	return c
+}
+
+func (c Cons) Count() int {
+	return countSeq(c)
+}
+
+func (c Cons) First() Value {
+	return c.first
+}
+
+func (c Cons) Next() Seq {
+	if c.more == nil {
+		return nil
+	}
+	return c.more.Seq()
+}
+
+func (v Cons) truthy() bool {
+	return true
+}
+
+func (v Cons) prn() string {
+	return prnSeq(v)
+}
+
+func (v Cons) String() string {
+	return v.prn()
+}
+
+// Walks a vector from an index, without copying it.
+type vectorSeq struct {
+	v Vector
+	i int
+}
+
+func (s vectorSeq) Seq() Seq {
+	return s
+}
+
+func (s vectorSeq) Count() int {
+	return s.v.Count() - s.i
+}
+
+func (s vectorSeq) First() Value {
+	v, _ := s.v.Get(s.i)
+	return v
+}
+
+func (s vectorSeq) Next() Seq {
+	if s.i+1 >= s.v.Count() {
+		return nil
+	}
+	return vectorSeq{s.v, s.i + 1}
+}
+
+func (v vectorSeq) truthy() bool {
+	return true
+}
+
+func (v vectorSeq) prn() string {
+	return prnSeq(v)
+}
+
+func (v vectorSeq) String() string {
+	return v.prn()
+}
+
+// nil is the empty sequence.
+
+func (v Nil) Seq() Seq {
+	return nil
+}
+
+func (v Nil) Count() int {
+	return 0
+}
+
+// A string is a sequence of one-character strings.
+
+func (v Str) Seq() Seq {
+	if v == "" {
+		return nil
+	}
+
+	chars := make([]Value, 0, len(v))
+	for _, r := range string(v) {
+		chars = append(chars, Str(string(r)))
+	}
+	return NewList(chars...).Seq()
+}
+
+func (v Str) Count() int {
+	return utf8.RuneCountInString(string(v))
+}
+
+// A Sexpr is its own Seq, sharing its backing array with the Seqs after it.
+
+func (v Sexpr) Seq() Seq {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+func (v Sexpr) Count() int {
+	return len(v)
+}
+
+func (v Sexpr) First() Value {
+	return v[0]
+}
+
+func (v Sexpr) Next() Seq {
+	if len(v) < 2 {
+		return nil
+	}
+	return v[1:]
+}
@@ -0,0 +1,63 @@
+package goober
+
+import "testing"
+
+// A Seqable defined outside of goober's own collections, counting down from
+// n to 1.
+type countdown int
+
+func (c countdown) truthy() bool { return true }
+func (c countdown) prn() string  { return prnSeq(c.Seq()) }
+func (c countdown) Count() int   { return int(c) }
+
+func (c countdown) Seq() Seq {
+	if c == 0 {
+		return nil
+	}
+	return countdownSeq(c)
+}
+
+type countdownSeq int
+
+func (s countdownSeq) truthy() bool { return true }
+func (s countdownSeq) prn() string  { return prnSeq(s) }
+func (s countdownSeq) Count() int   { return int(s) }
+func (s countdownSeq) Seq() Seq     { return s }
+func (s countdownSeq) First() Value { return Int(s) }
+
+func (s countdownSeq) Next() Seq {
+	return countdown(s - 1).Seq()
+}
+
+func TestUserSeqable(t *testing.T) {
+	ns := DefaultNs()
+	ns.def("c", countdown(3))
+	ns.def("none", countdown(0))
+
+	assertEqual(t, test_eval_ns(ns, "(first c)"), Int(3))
+	assertEqual(t, test_eval_ns(ns, "(nth c 2)"), Int(1))
+	assertEqual(t, test_eval_ns(ns, "(count c)"), Int(3))
+	assertEqual(t, test_eval_ns(ns, "(last c)"), Int(1))
+	assertEqual(t, test_eval_ns(ns, "(first (rest c))"), Int(2))
+	assertEqual(t, test_eval_ns(ns, "(seq none)"), Nil{})
+	assertEqual(t, test_eval_ns(ns, "(= (seq c) [3 2 1])"), Boolean(true))
+	assertEqual(t, test_eval_ns(ns, "(reverse (map inc c))"), NewList(Int(2), Int(3), Int(4)))
+	assertEqual(t, test_eval_ns(ns, "(str (cons 4 c))"), Str("(4 3 2 1)"))
+}
+
+func TestLazySeqRealizesOnce(t *testing.T) {
+	calls := 0
+	s := newLazySeq(func() Value {
+		calls++
+		return NewList(Int(1), Int(2))
+	})
+	assertEqual(t, calls, 0)
+
+	walked := Cons{Int(0), s}
+	assertEqual(t, calls, 0) // consing onto it doesn't realize it
+
+	assertEqual(t, seqElements(walked), []Value{Int(0), Int(1), Int(2)})
+	assertEqual(t, s.Count(), 2)
+	assertEqual(t, s.prn(), "(1 2)")
+	assertEqual(t, calls, 1)
+}
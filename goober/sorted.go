@@ -0,0 +1,462 @@
+package goober
+
+import "fmt"
+import "strings"
+
+// Sorted maps and sets, which keep their keys in order according to a
+// comparator. They are persistent AVL trees: an update copies the path from
+// the root to the node it changes, rebalancing along the way, and shares
+// everything else, so updates and lookups cost O(log n).
+
+// Returns a negative number if a sorts before b, a positive number if it
+// sorts after, and 0 if they are the same.
+type comparator func(a, b Value) int
+
+type sortedNode struct {
+	key, value  Value
+	left, right *sortedNode
+	height      int
+}
+
+func (n *sortedNode) getHeight() int {
+	if n == nil {
+		return 0
+	}
+	return n.height
+}
+
+func newSortedNode(key, value Value, left, right *sortedNode) *sortedNode {
+	height := left.getHeight()
+	if right.getHeight() > height {
+		height = right.getHeight()
+	}
+	return &sortedNode{key: key, value: value, left: left, right: right, height: height + 1}
+}
+
+// Builds a node, rotating if one side has grown two taller than the other.
+func balanceSorted(key, value Value, left, right *sortedNode) *sortedNode {
+	switch diff := left.getHeight() - right.getHeight(); {
+	case diff > 1:
+		if left.left.getHeight() < left.right.getHeight() { // left-right case
+			lr := left.right
+			return newSortedNode(lr.key, lr.value,
+				newSortedNode(left.key, left.value, left.left, lr.left),
+				newSortedNode(key, value, lr.right, right))
+		}
+		return newSortedNode(left.key, left.value, left.left, newSortedNode(key, value, left.right, right))
+	case diff < -1:
+		if right.right.getHeight() < right.left.getHeight() { // right-left case
+			rl := right.left
+			return newSortedNode(rl.key, rl.value,
+				newSortedNode(key, value, left, rl.left),
+				newSortedNode(right.key, right.value, rl.right, right.right))
+		}
+		return newSortedNode(right.key, right.value, newSortedNode(key, value, left, right.left), right.right)
+	default:
+		return newSortedNode(key, value, left, right)
+	}
+}
+
+func (n *sortedNode) get(cmp comparator, key Value) (*sortedNode, bool) {
+	for n != nil {
+		switch c := cmp(key, n.key); {
+		case c < 0:
+			n = n.left
+		case c > 0:
+			n = n.right
+		default:
+			return n, true
+		}
+	}
+	return nil, false
+}
+
+// Returns a tree that also maps key to value, and whether the key was added
+// rather than replacing an existing one.
+func (n *sortedNode) assoc(cmp comparator, key, value Value) (*sortedNode, bool) {
+	if n == nil {
+		return newSortedNode(key, value, nil, nil), true
+	}
+
+	switch c := cmp(key, n.key); {
+	case c < 0:
+		left, added := n.left.assoc(cmp, key, value)
+		return balanceSorted(n.key, n.value, left, n.right), added
+	case c > 0:
+		right, added := n.right.assoc(cmp, key, value)
+		return balanceSorted(n.key, n.value, n.left, right), added
+	default:
+		return newSortedNode(key, value, n.left, n.right), false
+	}
+}
+
+// Returns a tree without the key, and whether there was a key to remove.
+func (n *sortedNode) dissoc(cmp comparator, key Value) (*sortedNode, bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	switch c := cmp(key, n.key); {
+	case c < 0:
+		left, removed := n.left.dissoc(cmp, key)
+		if !removed {
+			return n, false
+		}
+		return balanceSorted(n.key, n.value, left, n.right), true
+	case c > 0:
+		right, removed := n.right.dissoc(cmp, key)
+		if !removed {
+			return n, false
+		}
+		return balanceSorted(n.key, n.value, n.left, right), true
+	case n.left == nil:
+		return n.right, true
+	case n.right == nil:
+		return n.left, true
+	default: // replace the node with the smallest node to its right
+		min, right := n.right.dissocMin()
+		return balanceSorted(min.key, min.value, n.left, right), true
+	}
+}
+
+func (n *sortedNode) dissocMin() (*sortedNode, *sortedNode) {
+	if n.left == nil {
+		return n, n.right
+	}
+	min, left := n.left.dissocMin()
+	return min, balanceSorted(n.key, n.value, left, n.right)
+}
+
+// Calls f with each node in order, ascending or descending, skipping the
+// subtrees that within says cannot hold a wanted key. within reports whether
+// keys below and above a node's key may be wanted, f itself is called with
+// every node it reaches.
+func (n *sortedNode) each(ascending bool, within func(key Value) (below, above bool), f func(*sortedNode)) {
+	if n == nil {
+		return
+	}
+
+	below, above := within(n.key)
+	first, second := n.left, n.right
+	firstOk, secondOk := below, above
+	if !ascending {
+		first, second = n.right, n.left
+		firstOk, secondOk = above, below
+	}
+
+	if firstOk {
+		first.each(ascending, within, f)
+	}
+	f(n)
+	if secondOk {
+		second.each(ascending, within, f)
+	}
+}
+
+func everything(Value) (bool, bool) {
+	return true, true
+}
+
+// An immutable map that keeps its keys sorted, by compare unless it was
+// created with a comparator of its own.
+//
+// The zero value is an empty map sorted by compare.
+type SortedMap struct {
+	root  *sortedNode
+	count int
+	cmp   comparator
+}
+
+func NewSortedMap(cmp comparator, kvs ...Value) SortedMap {
+	m := SortedMap{cmp: cmp}
+	for i := 0; i+1 < len(kvs); i += 2 {
+		m = m.Assoc(kvs[i], kvs[i+1])
+	}
+	return m
+}
+
+func (m SortedMap) comparator() comparator {
+	if m.cmp == nil {
+		return Compare
+	}
+	return m.cmp
+}
+
+func (m SortedMap) Count() int {
+	return m.count
+}
+
+func (m SortedMap) Get(k Value) (Value, bool) {
+	if n, ok := m.root.get(m.comparator(), k); ok {
+		return n.value, true
+	}
+	return nil, false
+}
+
+func (m SortedMap) Contains(k Value) bool {
+	_, ok := m.root.get(m.comparator(), k)
+	return ok
+}
+
+func (m SortedMap) Assoc(k, v Value) SortedMap {
+	root, added := m.root.assoc(m.comparator(), k, v)
+	if added {
+		return SortedMap{root: root, count: m.count + 1, cmp: m.cmp}
+	}
+	return SortedMap{root: root, count: m.count, cmp: m.cmp}
+}
+
+func (m SortedMap) Dissoc(k Value) SortedMap {
+	root, removed := m.root.dissoc(m.comparator(), k)
+	if !removed {
+		return m
+	}
+	return SortedMap{root: root, count: m.count - 1, cmp: m.cmp}
+}
+
+// Returns the entries in order.
+func (m SortedMap) entries() []mapEntry {
+	entries := make([]mapEntry, 0, m.count)
+	m.root.each(true, everything, func(n *sortedNode) {
+		entries = append(entries, mapEntry{n.key, n.value})
+	})
+	return entries
+}
+
+// Returns the entries whose keys pass the tests, in ascending or descending
+// order. A test is one of the comparison builtins, such as >=, applied as
+// (test entry-key key).
+func (m SortedMap) subseq(ascending bool, tests []Value, keys []Value) []mapEntry {
+	cmp := m.comparator()
+
+	checks := make([]func(int) bool, len(tests))
+	for i, test := range tests {
+		checks[i] = rangeTest(test)
+	}
+
+	// > and >= are lower bounds, so keys below a node's key are only wanted
+	// if the node's key is above the bound, and < and <= are upper bounds
+	within := func(key Value) (bool, bool) {
+		below, above := true, true
+		for i, check := range checks {
+			c := cmp(key, keys[i])
+			if lower := check(1); lower {
+				below = below && c > 0
+			} else {
+				above = above && c < 0
+			}
+		}
+		return below, above
+	}
+	pass := func(key Value) bool {
+		for i, check := range checks {
+			if !check(cmp(key, keys[i])) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var entries []mapEntry
+	m.root.each(ascending, within, func(n *sortedNode) {
+		if pass(n.key) {
+			entries = append(entries, mapEntry{n.key, n.value})
+		}
+	})
+	return entries
+}
+
+// Turns one of <, <=, > and >= into a check of a comparison's result.
+func rangeTest(test Value) func(int) bool {
+	if b, ok := test.(builtin); ok {
+		switch b.name {
+		case "<":
+			return func(c int) bool { return c < 0 }
+		case "<=":
+			return func(c int) bool { return c <= 0 }
+		case ">":
+			return func(c int) bool { return c > 0 }
+		case ">=":
+			return func(c int) bool { return c >= 0 }
+		}
+	}
+	panic(fmt.Sprintf("a range test must be one of <, <=, > or >=: %v", test))
+}
+
+func (m SortedMap) Seq() Seq {
+	return NewList(entryPairs(m.entries())...).Seq()
+}
+
+func (v SortedMap) truthy() bool {
+	return v.count > 0
+}
+
+func (v SortedMap) prn() string {
+	kvs := make([]string, 0, v.count*2)
+	for _, e := range v.entries() {
+		kvs = append(kvs, e.key.prn(), e.value.prn())
+	}
+
+	return "{" + strings.Join(kvs, " ") + "}"
+}
+
+func (v SortedMap) String() string {
+	return v.prn()
+}
+
+// An immutable set that keeps its elements sorted. It is a sorted map from
+// each element to itself.
+//
+// The zero value is an empty set sorted by compare.
+type SortedSet struct {
+	m SortedMap
+}
+
+func NewSortedSet(cmp comparator, elements ...Value) SortedSet {
+	set := SortedSet{SortedMap{cmp: cmp}}
+	for _, v := range elements {
+		set = set.Conj(v)
+	}
+	return set
+}
+
+func (s SortedSet) Count() int {
+	return s.m.Count()
+}
+
+func (s SortedSet) Contains(v Value) bool {
+	return s.m.Contains(v)
+}
+
+func (s SortedSet) Get(v Value) (Value, bool) {
+	return s.m.Get(v)
+}
+
+func (s SortedSet) Conj(v Value) SortedSet {
+	if s.Contains(v) {
+		return s
+	}
+	return SortedSet{s.m.Assoc(v, v)}
+}
+
+func (s SortedSet) Disj(v Value) SortedSet {
+	return SortedSet{s.m.Dissoc(v)}
+}
+
+// Returns the elements in order.
+func (s SortedSet) elements() []Value {
+	entries := s.m.entries()
+	elements := make([]Value, 0, len(entries))
+	for _, e := range entries {
+		elements = append(elements, e.key)
+	}
+	return elements
+}
+
+func (s SortedSet) Seq() Seq {
+	return NewList(s.elements()...).Seq()
+}
+
+func (v SortedSet) truthy() bool {
+	return true
+}
+
+func (v SortedSet) prn() string {
+	elements := make([]string, 0, v.Count())
+	for _, i := range v.elements() {
+		elements = append(elements, i.prn())
+	}
+
+	return "#{" + strings.Join(elements, " ") + "}"
+}
+
+func (v SortedSet) String() string {
+	return v.prn()
+}
+
+// Compares values of the same kind: numbers, strings, symbols, keywords,
+// booleans (false first) and vectors (shorter first, then element by
+// element). nil sorts before everything.
+func Compare(a, b Value) int {
+	if _, ok := a.(Nil); ok {
+		if _, ok := b.(Nil); ok {
+			return 0
+		}
+		return -1
+	}
+	if _, ok := b.(Nil); ok {
+		return 1
+	}
+
+	switch x := a.(type) {
+	case Int, BigInt, Ratio, Float:
+		if isNumber(b) {
+			if c, ok := compareNumbers(x, b); ok {
+				return c
+			}
+		}
+	case Str:
+		if y, ok := b.(Str); ok {
+			return strings.Compare(string(x), string(y))
+		}
+	case Symbol:
+		if y, ok := b.(Symbol); ok {
+			return strings.Compare(string(x), string(y))
+		}
+	case Keyword:
+		if y, ok := b.(Keyword); ok {
+			return strings.Compare(string(x), string(y))
+		}
+	case Boolean:
+		if y, ok := b.(Boolean); ok {
+			switch {
+			case x == y:
+				return 0
+			case bool(y):
+				return -1
+			default:
+				return 1
+			}
+		}
+	case Vector:
+		if y, ok := b.(Vector); ok {
+			if x.Count() != y.Count() {
+				return x.Count() - y.Count()
+			}
+			ys := y.elements()
+			for i, e := range x.elements() {
+				if c := Compare(e, ys[i]); c != 0 {
+					return c
+				}
+			}
+			return 0
+		}
+	}
+
+	panic(fmt.Sprintf("cannot compare %v and %v", a.prn(), b.prn()))
+}
+
+// Makes a comparator of a function, which either returns a number like
+// compare does, or is a predicate that is true when its first argument
+// sorts before its second, like <.
+func fnComparator(f IFn) comparator {
+	return func(a, b Value) int {
+		result := call(f, []Value{a, b})
+		switch r := result.(type) {
+		case Boolean:
+			if r {
+				return -1
+			}
+			if call(f, []Value{b, a}).truthy() {
+				return 1
+			}
+			return 0
+		default:
+			c, ok := compareNumbers(requireNumber(r, "a comparator must return a number or a boolean"), Int(0))
+			if !ok {
+				panic(fmt.Sprintf("a comparator returned %v", r.prn()))
+			}
+			return c
+		}
+	}
+}
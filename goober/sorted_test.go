@@ -0,0 +1,93 @@
+package goober
+
+import "testing"
+
+// Checks the AVL invariants: keys in order, recorded heights correct, and
+// subtrees never differing in height by more than one.
+func checkSorted(t *testing.T, n *sortedNode, lo, hi Value) int {
+	if n == nil {
+		return 0
+	}
+	if lo != nil && Compare(n.key, lo) <= 0 || hi != nil && Compare(n.key, hi) >= 0 {
+		t.Fatalf("%v is out of order", n.key)
+	}
+
+	left := checkSorted(t, n.left, lo, n.key)
+	right := checkSorted(t, n.right, n.key, hi)
+	if left-right > 1 || right-left > 1 {
+		t.Fatalf("unbalanced at %v: %v and %v", n.key, left, right)
+	}
+
+	height := left + 1
+	if right >= left {
+		height = right + 1
+	}
+	if height != n.height {
+		t.Fatalf("height of %v is %v, recorded as %v", n.key, height, n.height)
+	}
+	return height
+}
+
+func TestSortedMapBalance(t *testing.T) {
+	m := SortedMap{}
+	for i := 0; i < 1000; i++ {
+		k := Int((i * 7919) % 1000) // every key once, in a scrambled order
+		m = m.Assoc(k, Int(i))
+		checkSorted(t, m.root, nil, nil)
+	}
+	assertEqual(t, m.Count(), 1000)
+
+	before := m
+	for i := 0; i < 1000; i += 3 {
+		m = m.Dissoc(Int(i))
+		checkSorted(t, m.root, nil, nil)
+	}
+	assertEqual(t, m.Count(), 666)
+	assertEqual(t, before.Count(), 1000)
+
+	for i, e := range m.entries() {
+		if Compare(e.key, Int(i/2*3+i%2+1)) != 0 {
+			t.Fatalf("entry %v is %v", i, e.key)
+		}
+	}
+}
+
+func TestSortedSubseq(t *testing.T) {
+	m := SortedMap{}
+	for i := 0; i < 50; i += 2 {
+		m = m.Assoc(Int(i), Nil{})
+	}
+
+	tests := []string{"<", "<=", ">", ">="}
+	check := map[string]func(x, k int) bool{
+		"<":  func(x, k int) bool { return x < k },
+		"<=": func(x, k int) bool { return x <= k },
+		">":  func(x, k int) bool { return x > k },
+		">=": func(x, k int) bool { return x >= k },
+	}
+
+	// every range is compared with filtering the keys by hand
+	for _, lowTest := range tests {
+		for _, highTest := range tests {
+			for low := -1; low <= 51; low += 3 {
+				for high := -1; high <= 51; high += 5 {
+					found := m.subseq(true, []Value{builtinMap[lowTest].(builtin), builtinMap[highTest].(builtin)}, []Value{Int(low), Int(high)})
+
+					var want []int
+					for x := 0; x < 50; x += 2 {
+						if check[lowTest](x, low) && check[highTest](x, high) {
+							want = append(want, x)
+						}
+					}
+
+					if len(found) != len(want) {
+						t.Fatalf("%v %v %v %v found %v, want %v", lowTest, low, highTest, high, found, want)
+					}
+					for i := range want {
+						assertEqual(t, found[i].key, Int(want[i]))
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package goober
+
+import "strings"
+
+// The Lisp call stack, kept so that an error can say which calls it happened
+// in and where in the source they were made. Like the rest of the evaluator,
+// it assumes code is evaluated on one goroutine at a time.
+//
+// Each call pushes a frame, and pops it with a deferred popFrame. If the call
+// panics, the innermost popFrame turns the panic into an ExInfo carrying a
+// copy of the stack as it was, so the trace survives the unwinding.
+
+type frame struct {
+	name string
+	pos  Pos // where the call was made, or the zero Pos if that isn't known
+}
+
+func (f frame) String() string {
+	if f.pos.Line == 0 {
+		return "at " + f.name
+	}
+	return "at " + f.name + " (" + f.pos.String() + ")"
+}
+
+var stack []frame
+
+func pushFrame(f IFn, pos Pos) {
+	stack = append(stack, frame{name: frameName(f), pos: pos})
+}
+
+func frameName(f IFn) string {
+	if f, ok := f.(fn); ok {
+		return f.displayName()
+	}
+	return f.Name()
+}
+
+// Replaces the frame on top of the stack with one for a tail call, which
+// takes the place of the call that frame was for.
+func replaceFrame(f IFn, pos Pos) {
+	stack[len(stack)-1] = frame{name: frameName(f), pos: pos}
+}
+
+func popFrame() {
+	if e := recover(); e != nil {
+		ex := toException(e)
+		if ex.trace == nil {
+			ex.trace = backtrace()
+		}
+		stack = stack[:len(stack)-1]
+		panic(ex)
+	}
+	stack = stack[:len(stack)-1]
+}
+
+// Returns the frames on the stack, innermost first.
+func backtrace() []frame {
+	trace := make([]frame, 0, len(stack))
+	for i := len(stack) - 1; i >= 0; i-- {
+		trace = append(trace, stack[i])
+	}
+	return trace
+}
+
+// Where a Sexpr was read from. The reader keeps it in the Sexpr's own backing
+// array, in a slot just past its last element, so that it lives exactly as
+// long as the code it is for. Sexprs built as a program runs, such as the
+// expansions of macros, have none.
+type position struct {
+	pos Pos
+}
+
+func (v position) truthy() bool {
+	return true
+}
+
+func (v position) prn() string {
+	return "#<position " + v.pos.String() + ">"
+}
+
+// Returns a Sexpr with the elements of s, read from pos.
+func setPosition(s Sexpr, pos Pos) Sexpr {
+	s = append(s[:len(s):len(s)], position{pos})
+	return s[:len(s)-1]
+}
+
+// Returns where a Sexpr was read from, or the zero Pos if it wasn't read.
+func positionOf(s Sexpr) Pos {
+	if cap(s) > len(s) {
+		if p, ok := s[:len(s)+1][len(s)].(position); ok {
+			return p.pos
+		}
+	}
+	return Pos{}
+}
+
+// Returns the backtrace of an exception, one frame per line, innermost
+// first.
+func (v ExInfo) Backtrace() string {
+	lines := make([]string, 0, len(v.trace))
+	for _, f := range v.trace {
+		lines = append(lines, "  "+f.String()+"\n")
+	}
+	return strings.Join(lines, "")
+}
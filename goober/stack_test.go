@@ -0,0 +1,80 @@
+package goober
+
+import "testing"
+import "strings"
+
+// Evaluates a script, returning the exception it throws.
+func throwOf(t *testing.T, script string) (ex ExInfo) {
+	defer func() {
+		ex = recover().(ExInfo)
+	}()
+	ns := DefaultNs()
+	forms, err := NewReader("test.el", strings.NewReader(script)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, form := range forms {
+		Eval(ns, form)
+	}
+	t.Fatal("expected an exception")
+	return
+}
+
+func TestBacktrace(t *testing.T) {
+	ex := throwOf(t, `(defn f (x) (get x))
+(defn g (x)
+  (inc (f x)))
+(g 1)`)
+
+	assertEqual(t, ex.message, "get takes 2 or 3 parameters: [1]")
+	assertEqual(t, ex.Backtrace(), `  at get (test.el:1:13)
+  at f (test.el:3:8)
+  at g (test.el:4:1)
+`)
+	assertEqual(t, len(stack), 0)
+}
+
+func TestBacktraceOfTailCall(t *testing.T) {
+	ex := throwOf(t, `(defn f (x) (get x))
+(defn g (x)
+  (f x))
+(g 1)`)
+
+	// f is called in g's tail position, so its frame takes the place of g's
+	assertEqual(t, ex.Backtrace(), `  at get (test.el:1:13)
+  at f (test.el:3:3)
+`)
+	assertEqual(t, len(stack), 0)
+}
+
+func TestErrorOutsideCall(t *testing.T) {
+	ex := throwOf(t, "unbound-symbol")
+	assertEqual(t, ex.message, "cannot find a binding or var with this symbol name: unbound-symbol")
+	assertEqual(t, ex.Backtrace(), "")
+}
+
+func TestBacktraceOfThrow(t *testing.T) {
+	ex := throwOf(t, `(def boom (fn (x) (throw (ex-info "bad" {:x x}))))
+(str (map boom [1]))`)
+
+	assertEqual(t, ex.message, "bad")
+	assertEqual(t, ex.Backtrace(), "  at boom\n  at str (test.el:2:1)\n")
+}
+
+func TestBacktraceIsKeptWhenRethrown(t *testing.T) {
+	ex := throwOf(t, `(defn f () (throw (ex-info "inner" {})))
+(try
+  (f)
+  (catch e (throw e)))`)
+
+	assertEqual(t, ex.Backtrace(), "  at f (test.el:3:3)\n")
+}
+
+func TestAnonymousFnFrames(t *testing.T) {
+	ex := throwOf(t, `(let [f (fn (x) (/ 1 x))] (f 0))`)
+	assertEqual(t, ex.Backtrace(), "  at / (test.el:1:17)\n  at #<anonymous> (test.el:1:27)\n")
+
+	// a fn is only named for the var it is defined as
+	ex = throwOf(t, `(let [f (fn (x) (/ 1 x))] (def g f) (g 0))`)
+	assertEqual(t, ex.Backtrace(), "  at / (test.el:1:17)\n  at #<anonymous> (test.el:1:37)\n")
+}
@@ -0,0 +1,103 @@
+package goober
+
+import "fmt"
+import "os"
+import "strings"
+
+// Tracing logs calls to functions as they are made, with their arguments and
+// what they return, and the expansions of macros. (trace 'f 'g) traces the
+// functions and macros named f and g, and (trace) traces everything called
+// in the namespace; untrace undoes either. Each line is indented by the depth
+// of the call stack, so nested calls read as a tree.
+//
+// Calls are traced according to the namespace the caller was evaluated in,
+// or for a fn called by a builtin, the namespace the fn was made in.
+
+func (ns *Ns) tracing(f IFn) bool {
+	return ns != nil && (ns.traceAll || ns.traced[frameName(f)])
+}
+
+// How many traced calls are in progress, which is how far trace lines are
+// indented.
+var traceDepth int
+
+// Logs a line of a trace.
+func (ns *Ns) tracef(format string, args ...interface{}) {
+	w := ns.TraceWriter
+	if w == nil {
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, strings.Repeat("| ", traceDepth)+format+"\n", args...)
+}
+
+// Calls f with arguments that have already been evaluated, logging the call
+// and what it returns. The call's frame is already on the stack.
+func traceCall(ns *Ns, f IFn, args []Value) Value {
+
+	elements := make([]string, 0, len(args)+1)
+	elements = append(elements, frameName(f))
+	for _, arg := range args {
+		elements = append(elements, tracePrn(arg))
+	}
+	ns.tracef("(%v)", strings.Join(elements, " "))
+
+	result := callIndented(f, args)
+	ns.tracef("=> %v", tracePrn(result))
+	return result
+}
+
+// Calls f with the lines it traces indented one level further.
+func callIndented(f IFn, args []Value) Value {
+	traceDepth++
+	defer func() { traceDepth-- }()
+	return callEvaluated(f, args)
+}
+
+func traceExpansion(ns *Ns, form Value, expanded Value) {
+	ns.tracef("%v expands to %v", form.prn(), expanded.prn())
+}
+
+// Prints a value for a trace, without printing a lazy sequence, which would
+// realize it, and might never end.
+func tracePrn(v Value) string {
+	switch v.(type) {
+	case *LazySeq, Cons, Eduction:
+		return "#<lazy-seq>"
+	default:
+		return v.prn()
+	}
+}
+
+// (trace sym*)
+func special_trace(context *context, vals []Value) Value {
+
+	if len(vals) == 0 {
+		context.ns.traceAll = true
+	}
+
+	for _, v := range evalAll(context, vals) {
+		sym := requireSymbol(v, "trace takes symbols naming functions")
+		if context.ns.traced == nil {
+			context.ns.traced = map[string]bool{}
+		}
+		context.ns.traced[string(sym)] = true
+	}
+
+	return Nil{}
+}
+
+// (untrace sym*). With no symbols, stops tracing everything.
+func special_untrace(context *context, vals []Value) Value {
+
+	if len(vals) == 0 {
+		context.ns.traceAll = false
+		context.ns.traced = nil
+	}
+
+	for _, v := range evalAll(context, vals) {
+		sym := requireSymbol(v, "untrace takes symbols naming functions")
+		delete(context.ns.traced, string(sym))
+	}
+
+	return Nil{}
+}
@@ -0,0 +1,73 @@
+package goober
+
+import "testing"
+import "bytes"
+
+// Evaluates each form with the namespace's traces written to a buffer,
+// returning what was written.
+func traceOf(ns *Ns, forms ...string) string {
+	var buf bytes.Buffer
+	ns.TraceWriter = &buf
+	defer func() {
+		ns.TraceWriter = nil
+		test_eval_ns(ns, "(untrace)")
+	}()
+
+	for _, form := range forms {
+		test_eval_ns(ns, form)
+	}
+	return buf.String()
+}
+
+func TestTraceFn(t *testing.T) {
+	ns := DefaultNs()
+	test_eval_ns(ns, "(defn trace-fib (n) (if (< n 2) n (+ (trace-fib (- n 1)) (trace-fib (- n 2)))))")
+
+	assertEqual(t, traceOf(ns, "(trace 'trace-fib)", "(trace-fib 3)"), `(trace-fib 3)
+| (trace-fib 2)
+| | (trace-fib 1)
+| | => 1
+| | (trace-fib 0)
+| | => 0
+| => 1
+| (trace-fib 1)
+| => 1
+=> 2
+`)
+
+	assertEqual(t, traceOf(ns, "(trace 'trace-fib)", "(untrace 'trace-fib)", "(trace-fib 3)"), "")
+}
+
+func TestTraceAll(t *testing.T) {
+	ns := DefaultNs()
+
+	assertEqual(t, traceOf(ns, "(trace)", "(+ 1 (count [:a]))"), `(count [:a])
+=> 1
+(+ 1 1)
+=> 2
+`)
+
+	// lazy sequences aren't realized to print them
+	assertEqual(t, traceOf(ns, "(trace 'range 'take)", "(take 2 (range))"), `(range)
+=> #<lazy-seq>
+(take 2 #<lazy-seq>)
+=> #<lazy-seq>
+`)
+}
+
+func TestTraceCalledByBuiltin(t *testing.T) {
+	ns := DefaultNs()
+	test_eval_ns(ns, "(defn trace-double (x) (* 2 x))")
+
+	assertEqual(t, traceOf(ns, "(trace 'trace-double)", "(reduce + (map trace-double [1 2]))"), `(trace-double 1)
+=> 2
+(trace-double 2)
+=> 4
+`)
+}
+
+func TestTraceMacro(t *testing.T) {
+	ns := DefaultNs()
+
+	assertEqual(t, traceOf(ns, "(trace 'when)", "(when true 1)"), "(when true 1) expands to (if true (do 1))\n")
+}
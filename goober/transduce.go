@@ -0,0 +1,221 @@
+package goober
+
+import "fmt"
+
+// Transducers. A reducing function is a function of an accumulated value and
+// a new value, like the f given to reduce, which can also be called with no
+// arguments for an initial value and with just the accumulated value once
+// there are no more values. A transducer turns one reducing function into
+// another, so (map inc) turns conj into a function that conjes one more than
+// each value. Transducers compose with comp, and as they work one value at a
+// time, a pipeline of them builds no sequence between its steps.
+//
+// The transducers here are builtins returning builtins, so the reducing
+// functions they return can keep state, like how many values take has let
+// through, for the one reduction they are made for.
+
+// Wraps the accumulated value to end a reduction early, as take does once it
+// has had enough values.
+type Reduced struct {
+	v Value
+}
+
+func (v Reduced) truthy() bool {
+	return true
+}
+
+func (v Reduced) prn() string {
+	return "#reduced " + v.v.prn()
+}
+
+func (v Reduced) String() string {
+	return v.prn()
+}
+
+func unreduced(v Value) Value {
+	if r, ok := v.(Reduced); ok {
+		return r.v
+	}
+	return v
+}
+
+// Returns a transducer, with xf making the reducing function it turns rf
+// into.
+func transducer(name string, xf func(rf IFn) builtin_f) builtin {
+	return builtin{name, func(vals []Value) Value {
+		if len(vals) != 1 {
+			panic(fmt.Sprintf("a transducer takes only 1 parameter: %v", vals))
+		}
+		rf := requireIFn(vals[0], "a transducer takes a reducing function")
+		return builtin{name, xf(rf)}
+	}}
+}
+
+func mapping(f IFn) builtin {
+	return transducer("map", func(rf IFn) builtin_f {
+		return func(vals []Value) Value {
+			if len(vals) < 2 {
+				return call(rf, vals)
+			}
+			return call(rf, []Value{vals[0], call(f, vals[1:])})
+		}
+	})
+}
+
+func filtering(pred IFn) builtin {
+	return transducer("filter", func(rf IFn) builtin_f {
+		return func(vals []Value) Value {
+			if len(vals) < 2 || call(pred, vals[1:]).truthy() {
+				return call(rf, vals)
+			}
+			return vals[0]
+		}
+	})
+}
+
+func taking(n int) builtin {
+	return transducer("take", func(rf IFn) builtin_f {
+		left := n
+		return func(vals []Value) Value {
+			if len(vals) < 2 {
+				return call(rf, vals)
+			}
+			acc := vals[0]
+			if left > 0 {
+				acc = call(rf, vals)
+			}
+			if left--; left <= 0 {
+				if _, ok := acc.(Reduced); !ok {
+					acc = Reduced{acc}
+				}
+			}
+			return acc
+		}
+	})
+}
+
+// Collects values into vectors of n, passing on what is left over, if
+// anything, when the reduction completes.
+func partitioningAll(n int) builtin {
+	return transducer("partition-all", func(rf IFn) builtin_f {
+		var part []Value
+		return func(vals []Value) Value {
+			switch len(vals) {
+			case 0:
+				return call(rf, vals)
+			case 1:
+				acc := vals[0]
+				if len(part) > 0 {
+					acc = unreduced(call(rf, []Value{acc, NewVector(part...)}))
+					part = nil
+				}
+				return call(rf, []Value{acc})
+			}
+			if part = append(part, vals[1]); len(part) < n {
+				return vals[0]
+			}
+			full := NewVector(part...)
+			part = nil
+			return call(rf, []Value{vals[0], full})
+		}
+	})
+}
+
+func applyTransducer(xform IFn, rf Value) IFn {
+	return requireIFn(call(xform, []Value{rf}), "a transducer must return a function")
+}
+
+// Returns a reducing function that calls f to step, and completes by
+// returning the accumulated value as it is.
+func completing(f IFn) builtin {
+	return builtin{f.Name(), func(vals []Value) Value {
+		if len(vals) == 1 {
+			return vals[0]
+		}
+		return call(f, vals)
+	}}
+}
+
+// Reduces coll with rf, stopping early if rf returns a Reduced, then calls
+// rf with the result to complete it.
+func transduce(rf IFn, acc Value, coll Value) Value {
+	for s := toSeq(coll); s != nil; s = s.Next() {
+		acc = call(rf, []Value{acc, s.First()})
+		if r, ok := acc.(Reduced); ok {
+			acc = r.v
+			break
+		}
+	}
+	return call(rf, []Value{acc})
+}
+
+// Applies xform to coll a value at a time, as the values are walked. The
+// values xform produces for each value of coll are collected in buf until
+// they are walked.
+func lazyTransduce(xform IFn, coll Value) *LazySeq {
+	buf := &[]Value{}
+	rf := applyTransducer(xform, builtin{"sequence", func(vals []Value) Value {
+		if len(vals) == 2 {
+			*buf = append(*buf, vals[1])
+		}
+		if len(vals) == 0 {
+			return Nil{}
+		}
+		return vals[0]
+	}})
+	return lazyBuffered(rf, buf, coll)
+}
+
+// Steps rf through coll until it has added to buf, or coll runs out. A nil
+// coll means the reduction is done.
+func lazyBuffered(rf IFn, buf *[]Value, coll Value) *LazySeq {
+	return newLazySeq(func() Value {
+		for len(*buf) == 0 && coll != nil {
+			s := toSeq(coll)
+			if s == nil {
+				call(rf, []Value{Nil{}})
+				coll = nil
+				break
+			}
+			if _, ok := call(rf, []Value{Nil{}, s.First()}).(Reduced); ok {
+				call(rf, []Value{Nil{}})
+				coll = nil
+				break
+			}
+			coll = more(s)
+		}
+		if len(*buf) == 0 {
+			return Nil{}
+		}
+		x := (*buf)[0]
+		*buf = (*buf)[1:]
+		return Cons{x, lazyBuffered(rf, buf, coll)}
+	})
+}
+
+// A collection with a transducer applied to it, which applies it afresh each
+// time the eduction is walked or reduced.
+type Eduction struct {
+	xform IFn
+	coll  Value
+}
+
+func (e Eduction) Seq() Seq {
+	return lazyTransduce(e.xform, e.coll).Seq()
+}
+
+func (e Eduction) Count() int {
+	return countSeq(e.Seq())
+}
+
+func (v Eduction) truthy() bool {
+	return true
+}
+
+func (v Eduction) prn() string {
+	return prnSeq(v.Seq())
+}
+
+func (v Eduction) String() string {
+	return v.prn()
+}
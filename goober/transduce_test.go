@@ -0,0 +1,32 @@
+package goober
+
+import "testing"
+
+func TestSequenceIsLazy(t *testing.T) {
+	calls := 0
+	ns := DefaultNs()
+	ns.def("counted-inc", builtin{"counted-inc", func(vals []Value) Value {
+		calls++
+		return addition.apply(vals[0], Int(1))
+	}})
+
+	test_eval_ns(ns, "(def s (sequence (map counted-inc) (range)))")
+	assertEqual(t, calls, 0)
+
+	assertEqual(t, test_eval_ns(ns, "(str (take 3 s))"), Str("(1 2 3)"))
+	assertEqual(t, calls, 3)
+
+	assertEqual(t, test_eval_ns(ns, "(first s)"), Int(1)) // already realized
+	assertEqual(t, calls, 3)
+}
+
+func TestTransducerStateIsPerReduction(t *testing.T) {
+	ns := DefaultNs()
+	test_eval_ns(ns, "(def xf (comp (take 2) (partition-all 2)))")
+
+	// each reduction gets its own count for take and its own partition
+	assertEqual(t, test_eval_ns(ns, "(into [] xf [1 2 3])"), NewVector(NewVector(Int(1), Int(2))))
+	assertEqual(t, test_eval_ns(ns, "(into [] xf [4 5 6])"), NewVector(NewVector(Int(4), Int(5))))
+	assertEqual(t, test_eval_ns(ns, "(str (eduction xf [7]))"), Str("([7])"))
+	assertEqual(t, test_eval_ns(ns, "(str (eduction xf [7]))"), Str("([7])"))
+}
@@ -0,0 +1,235 @@
+package goober
+
+import "strings"
+
+// An immutable vector, with indexed access and appending at the end. It is a
+// persistent vector: a trie of 32-way nodes holding the elements in order,
+// plus a tail of up to 32 elements kept out of the trie, so that appending
+// usually only copies the tail. When the tail fills up it is pushed into the
+// trie as a leaf. Like hamt.go, an update copies the path it changes and
+// shares everything else, so lookups and updates cost O(log32 n).
+//
+// The zero value is an empty vector.
+type Vector struct {
+	count int
+	shift uint        // of the root, 0 if the root is a leaf
+	root  *vectorNode // nil until the first tail is pushed into the trie
+	tail  []Value
+}
+
+const (
+	vectorBits  = 5
+	vectorWidth = 1 << vectorBits
+	vectorMask  = vectorWidth - 1
+)
+
+// A node is a branch, with children, or a leaf, with 32 values.
+type vectorNode struct {
+	children []*vectorNode
+	values   []Value
+}
+
+func NewVector(elements ...Value) Vector {
+	v := Vector{}
+	for _, e := range elements {
+		v = v.Conj(e)
+	}
+	return v
+}
+
+func (v Vector) Count() int {
+	return v.count
+}
+
+// The index of the first element in the tail.
+func (v Vector) tailOffset() int {
+	if v.count == 0 {
+		return 0
+	}
+	return (v.count - 1) &^ vectorMask
+}
+
+// Returns the leaf holding the element at i, which must be in the trie.
+func (v Vector) leafFor(i int) *vectorNode {
+	node := v.root
+	for shift := v.shift; shift > 0; shift -= vectorBits {
+		node = node.children[(i>>shift)&vectorMask]
+	}
+	return node
+}
+
+func (v Vector) Get(i int) (Value, bool) {
+	if i < 0 || i >= v.count {
+		return nil, false
+	}
+	if i >= v.tailOffset() {
+		return v.tail[i-v.tailOffset()], true
+	}
+	return v.leafFor(i).values[i&vectorMask], true
+}
+
+// Returns a vector with x added at the end.
+func (v Vector) Conj(x Value) Vector {
+
+	if len(v.tail) < vectorWidth {
+		tail := make([]Value, len(v.tail), len(v.tail)+1)
+		copy(tail, v.tail)
+		return Vector{count: v.count + 1, shift: v.shift, root: v.root, tail: append(tail, x)}
+	}
+
+	// the tail is full, so it becomes a leaf of the trie
+	leaf := &vectorNode{values: v.tail}
+	tailOffset := v.count - vectorWidth
+	root, shift := v.root, v.shift
+
+	switch {
+	case root == nil:
+		root = leaf
+	case tailOffset == 1<<(shift+vectorBits): // the trie is full, add a level
+		root = &vectorNode{children: []*vectorNode{root, newVectorPath(shift, leaf)}}
+		shift += vectorBits
+	default:
+		root = pushVectorLeaf(shift, root, tailOffset, leaf)
+	}
+
+	return Vector{count: v.count + 1, shift: shift, root: root, tail: []Value{x}}
+}
+
+// Returns a chain of single-child branches leading down to leaf.
+func newVectorPath(shift uint, leaf *vectorNode) *vectorNode {
+	if shift == 0 {
+		return leaf
+	}
+	return &vectorNode{children: []*vectorNode{newVectorPath(shift-vectorBits, leaf)}}
+}
+
+// Returns a copy of the branch with leaf added as the leaf for index i.
+func pushVectorLeaf(shift uint, branch *vectorNode, i int, leaf *vectorNode) *vectorNode {
+	sub := (i >> shift) & vectorMask
+	children := make([]*vectorNode, len(branch.children), len(branch.children)+1)
+	copy(children, branch.children)
+
+	switch {
+	case shift == vectorBits:
+		children = append(children, leaf)
+	case sub < len(children):
+		children[sub] = pushVectorLeaf(shift-vectorBits, children[sub], i, leaf)
+	default:
+		children = append(children, newVectorPath(shift-vectorBits, leaf))
+	}
+
+	return &vectorNode{children: children}
+}
+
+// Returns a vector with the element at i replaced by x. An i equal to the
+// count appends x. Panics if i is out of bounds.
+func (v Vector) Assoc(i int, x Value) Vector {
+
+	switch {
+	case i == v.count:
+		return v.Conj(x)
+	case i < 0 || i > v.count:
+		panic("index out of bounds")
+	case i >= v.tailOffset():
+		tail := make([]Value, len(v.tail))
+		copy(tail, v.tail)
+		tail[i-v.tailOffset()] = x
+		return Vector{count: v.count, shift: v.shift, root: v.root, tail: tail}
+	default:
+		root := assocVector(v.shift, v.root, i, x)
+		return Vector{count: v.count, shift: v.shift, root: root, tail: v.tail}
+	}
+}
+
+func assocVector(shift uint, node *vectorNode, i int, x Value) *vectorNode {
+	if shift == 0 {
+		values := make([]Value, len(node.values))
+		copy(values, node.values)
+		values[i&vectorMask] = x
+		return &vectorNode{values: values}
+	}
+
+	sub := (i >> shift) & vectorMask
+	children := make([]*vectorNode, len(node.children))
+	copy(children, node.children)
+	children[sub] = assocVector(shift-vectorBits, children[sub], i, x)
+	return &vectorNode{children: children}
+}
+
+// Returns a vector without its last element. Panics if the vector is empty.
+func (v Vector) Pop() Vector {
+
+	switch {
+	case v.count == 0:
+		panic("can't pop an empty vector")
+	case v.count == 1:
+		return Vector{}
+	case len(v.tail) > 1:
+		// Conj always copies the tail, so sharing a prefix of it is safe
+		return Vector{count: v.count - 1, shift: v.shift, root: v.root, tail: v.tail[:len(v.tail)-1]}
+	}
+
+	// the tail is emptied, so the last leaf of the trie becomes the tail
+	tail := v.leafFor(v.count - 2).values
+	root, shift := popVectorLeaf(v.shift, v.root), v.shift
+	if shift > 0 && len(root.children) == 1 { // drop a level that is no longer needed
+		root, shift = root.children[0], shift-vectorBits
+	}
+
+	return Vector{count: v.count - 1, shift: shift, root: root, tail: tail}
+}
+
+// Returns a copy of the node without its last leaf, or nil if that leaves
+// it empty.
+func popVectorLeaf(shift uint, node *vectorNode) *vectorNode {
+	if shift == 0 {
+		return nil
+	}
+
+	last := len(node.children) - 1
+	child := popVectorLeaf(shift-vectorBits, node.children[last])
+	if child == nil && last == 0 {
+		return nil
+	}
+
+	children := make([]*vectorNode, len(node.children))
+	copy(children, node.children)
+	if child == nil {
+		children = children[:last]
+	} else {
+		children[last] = child
+	}
+	return &vectorNode{children: children}
+}
+
+func (v Vector) Seq() Seq {
+	if v.count == 0 {
+		return nil
+	}
+	return vectorSeq{v, 0}
+}
+
+func (v Vector) elements() []Value {
+	elements := make([]Value, 0, v.count)
+	for i := 0; i < v.tailOffset(); i += vectorWidth {
+		elements = append(elements, v.leafFor(i).values...)
+	}
+	return append(elements, v.tail...)
+}
+
+func (v Vector) truthy() bool {
+	return true
+}
+
+func (v Vector) prn() string {
+	elements := make([]string, 0, v.count)
+	for _, i := range v.elements() {
+		elements = append(elements, i.prn())
+	}
+
+	return "[" + strings.Join(elements, " ") + "]"
+}
+
+func (v Vector) String() string {
+	return v.prn()
+}
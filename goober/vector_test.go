@@ -0,0 +1,65 @@
+package goober
+
+import "reflect"
+import "testing"
+
+func TestVectorGrowAndShrink(t *testing.T) {
+	// enough elements for a trie three levels deep
+	const n = 40000
+
+	v := Vector{}
+	versions := []Vector{v}
+	for i := 0; i < n; i++ {
+		v = v.Conj(Int(i))
+		versions = append(versions, v)
+	}
+
+	for i := 0; i < n; i++ {
+		x, ok := v.Get(i)
+		if !ok || x != Int(i) {
+			t.Fatalf("element %v is %v, %v", i, x, ok)
+		}
+	}
+	_, ok := v.Get(n)
+	assertEqual(t, ok, false)
+	_, ok = v.Get(-1)
+	assertEqual(t, ok, false)
+
+	// popping retraces the same structures that conj built
+	for i := n; i > 0; i-- {
+		if !reflect.DeepEqual(v, versions[i]) {
+			t.Fatalf("popping to %v elements differs from conj", i)
+		}
+		v = v.Pop()
+	}
+	assertEqual(t, v, Vector{})
+
+	assertEqual(t, versions[1000].Count(), 1000)
+	assertEqual(t, len(versions[1057].elements()), 1057)
+}
+
+func TestVectorAssoc(t *testing.T) {
+	v := NewVector()
+	for i := 0; i < 1100; i++ {
+		v = v.Conj(Int(i))
+	}
+
+	w := v
+	for i := 0; i < 1100; i += 7 {
+		w = w.Assoc(i, Str("x"))
+	}
+	w = w.Assoc(1100, Str("end"))
+
+	for i := 0; i < 1100; i++ {
+		x, _ := v.Get(i)
+		assertEqual(t, x, Int(i)) // the original is untouched
+
+		y, _ := w.Get(i)
+		if i%7 == 0 {
+			assertEqual(t, y, Str("x"))
+		} else {
+			assertEqual(t, y, Int(i))
+		}
+	}
+	assertEqual(t, w.Count(), 1101)
+}